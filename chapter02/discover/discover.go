@@ -0,0 +1,258 @@
+// Package discover 包根据网站的地址查找这个网站提供的数据源
+package discover
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"notes.goinaction/chapter02/search"
+)
+
+// maxPageSize 是读取网站首页的最大字节数
+const maxPageSize = 1 << 20
+
+// ErrNotFound 会在网站上找不到任何数据源时返回
+var ErrNotFound = errors.New("no feed found")
+
+// feedTypes 将 <link> 标签的 type 属性对应到数据源的类型
+var feedTypes = map[string]string{
+	"application/rss+xml":  "rss",
+	"application/atom+xml": "atom",
+}
+
+// commonPaths 是网站上常见的数据源路径，页面里没有声明数据源时会依次尝试
+var commonPaths = []string{
+	"/feed",
+	"/rss",
+	"/feed.xml",
+	"/rss.xml",
+	"/atom.xml",
+	"/index.xml",
+	"/feeds/posts/default?alt=rss",
+}
+
+var (
+	// linkTag 匹配 HTML 里的 <link> 标签。页面往往不是合法的 XML，所以这里使用正则表达式而不是 XML 解码器
+	linkTag = regexp.MustCompile(`(?is)<link\b[^>]*>`)
+
+	// attribute 匹配标签里的属性，属性值可以使用双引号、单引号或者不使用引号
+	attribute = regexp.MustCompile(`(?s)([a-zA-Z_:-]+)\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'>]+))`)
+)
+
+// Discoverer 使用指定的 HTTP 客户端查找数据源
+type Discoverer struct {
+	client http.Client
+}
+
+// New 返回一个请求超时时间为 timeout 的 Discoverer
+func New(timeout time.Duration) *Discoverer {
+	return &Discoverer{
+		client: http.Client{Timeout: timeout},
+	}
+}
+
+/*
+Discover 返回网站上找到的数据源，可以直接追加到数据源列表里
+
+首先查找首页里 rel="alternate" 的 <link> 标签，如果一个也没有找到，再依次尝试常见的数据源路径。
+*/
+func (d *Discoverer) Discover(site string) ([]*search.Feed, error) {
+	base, err := url.Parse(site)
+	if err != nil {
+		return nil, err
+	}
+	if base.Scheme == "" {
+		if base, err = url.Parse("http://" + site); err != nil {
+			return nil, err
+		}
+	}
+
+	// 首页请求失败时仍然尝试常见路径，只有什么都没找到时才报告首页的错误
+	feeds, err := d.fromPage(base)
+	if len(feeds) == 0 {
+		feeds = d.probe(base)
+	}
+
+	if len(feeds) == 0 {
+		if err != nil {
+			return nil, err
+		}
+		return nil, ErrNotFound
+	}
+
+	uniqueNames(feeds)
+	return feeds, nil
+}
+
+// fromPage 请求网站首页，返回页面里声明的数据源
+func (d *Discoverer) fromPage(base *url.URL) ([]*search.Feed, error) {
+	resp, err := d.client.Get(base.String())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP Response Error %d", resp.StatusCode)
+	}
+
+	// 数据源的声明都在 <head> 里，只读取页面开头的部分就足够了
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxPageSize))
+	if err != nil {
+		return nil, err
+	}
+
+	// 相对地址要根据重定向之后的地址来解析
+	return parseLinks(resp.Request.URL, string(body)), nil
+}
+
+// parseLinks 从 HTML 页面里找出所有声明数据源的 <link> 标签
+func parseLinks(base *url.URL, page string) []*search.Feed {
+	var feeds []*search.Feed
+	seen := make(map[string]bool)
+
+	for _, tag := range linkTag.FindAllString(page, -1) {
+		attrs := make(map[string]string)
+		for _, m := range attribute.FindAllStringSubmatch(tag, -1) {
+			attrs[strings.ToLower(m[1])] = html.UnescapeString(m[2] + m[3] + m[4])
+		}
+
+		if !hasToken(attrs["rel"], "alternate") {
+			continue
+		}
+
+		feedType, exists := feedTypes[strings.ToLower(strings.TrimSpace(attrs["type"]))]
+		if !exists || attrs["href"] == "" {
+			continue
+		}
+
+		ref, err := base.Parse(strings.TrimSpace(attrs["href"]))
+		if err != nil || seen[ref.String()] {
+			continue
+		}
+		seen[ref.String()] = true
+
+		feeds = append(feeds, &search.Feed{
+			Name: feedName(attrs["title"], ref),
+			URI:  ref.String(),
+			Type: feedType,
+		})
+	}
+
+	return feeds
+}
+
+// probe 依次请求常见的数据源路径，返回内容确实是数据源的地址
+func (d *Discoverer) probe(base *url.URL) []*search.Feed {
+	var feeds []*search.Feed
+
+	for _, path := range commonPaths {
+		ref, err := base.Parse(path)
+		if err != nil {
+			continue
+		}
+
+		feedType, err := d.sniff(ref.String())
+		if err != nil {
+			continue
+		}
+
+		feeds = append(feeds, &search.Feed{
+			Name: feedName("", ref),
+			URI:  ref.String(),
+			Type: feedType,
+		})
+	}
+
+	return feeds
+}
+
+// sniff 请求一个地址，根据文档的根元素判断数据源的类型
+func (d *Discoverer) sniff(uri string) (string, error) {
+	resp, err := d.client.Get(uri)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("HTTP Response Error %d", resp.StatusCode)
+	}
+
+	return sniffType(resp.Body)
+}
+
+// sniffType 读取文档的第一个元素，rss 表示 RSS 数据源，feed 表示 Atom 数据源
+func sniffType(r io.Reader) (string, error) {
+	decoder := xml.NewDecoder(r)
+	decoder.Strict = false
+
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return "", err
+		}
+
+		if start, ok := token.(xml.StartElement); ok {
+			switch start.Name.Local {
+			case "rss":
+				return "rss", nil
+			case "feed":
+				return "atom", nil
+			default:
+				return "", fmt.Errorf("unknown root element %q", start.Name.Local)
+			}
+		}
+	}
+}
+
+// feedName 使用 <link> 标签的标题作为数据源的名字，没有标题时使用主机名和路径
+func feedName(title string, ref *url.URL) string {
+	if title = strings.TrimSpace(title); title != "" {
+		return title
+	}
+	return ref.Host + ref.RequestURI()
+}
+
+/*
+uniqueNames 给重名的数据源的名字加上主机名和路径
+
+数据源列表使用名字区分数据源，同一个网站的多个数据源经常使用相同的标题，例如都叫 "RSS"。
+*/
+func uniqueNames(feeds []*search.Feed) {
+	count := make(map[string]int)
+	for _, feed := range feeds {
+		count[feed.Name]++
+	}
+
+	for _, feed := range feeds {
+		if count[feed.Name] < 2 {
+			continue
+		}
+		ref, err := url.Parse(feed.URI)
+		if err != nil {
+			continue
+		}
+		if location := ref.Host + ref.RequestURI(); feed.Name != location {
+			feed.Name = fmt.Sprintf("%s (%s)", feed.Name, location)
+		}
+	}
+}
+
+// hasToken 报告以空白分隔的属性值里是否包含 token，例如 rel="alternate feed"
+func hasToken(value, token string) bool {
+	for _, field := range strings.Fields(value) {
+		if strings.EqualFold(field, token) {
+			return true
+		}
+	}
+	return false
+}
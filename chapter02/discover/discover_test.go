@@ -0,0 +1,168 @@
+// 这个示例程序使用模仿的网站测试数据源的自动发现
+package discover_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"notes.goinaction/chapter02/discover"
+	_ "notes.goinaction/chapter02/matchers"
+	"notes.goinaction/chapter02/search"
+)
+
+const checkMark = "\u2713"
+const ballotX = "\u2717"
+
+// page 是一个声明了数据源的首页，两个 <link> 使用相同的标题，属性的写法各不相同
+var page = `<!DOCTYPE html>
+<html>
+<head>
+<link rel="stylesheet" href="/style.css">
+<LINK REL="alternate" TYPE="application/rss+xml" TITLE="News" HREF="/news.rss">
+<link rel='alternate feed' type='application/atom+xml' title='News' href='https://other.example.com/atom.xml'>
+<link rel=alternate type=application/rss+xml href=/news.rss>
+<link rel="alternate" type="text/html" href="/zh/">
+</head>
+<body></body>
+</html>`
+
+// atom 是一个最简单的 Atom 文档
+var atom = `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+	<title>Example</title>
+	<entry>
+		<title>Concurrency Patterns</title>
+		<link href="http://example.com/concurrency"/>
+		<id>urn:uuid:1</id>
+		<updated>2015-03-16T15:04:00Z</updated>
+		<summary>Channels and goroutines.</summary>
+	</entry>
+</feed>`
+
+// rss 是一个最简单的 rss 文档
+var rss = `<?xml version="1.0"?><rss version="2.0"><channel><title>Example</title></channel></rss>`
+
+// TestDiscoverFromPage 确认首页里声明的数据源会被找到，并且名字各不相同
+func TestDiscoverFromPage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, page)
+	}))
+	defer server.Close()
+
+	t.Log("Given the need to discover feeds declared in a page.")
+	{
+		feeds, err := discover.New(time.Second).Discover(server.URL)
+		if err != nil {
+			t.Fatal("\tShould discover feeds.", ballotX, err)
+		}
+
+		if len(feeds) == 2 {
+			t.Log("\tShould find each feed once.", checkMark)
+		} else {
+			t.Fatal("\tShould find each feed once.", ballotX, len(feeds))
+		}
+
+		if feeds[0].URI == server.URL+"/news.rss" && feeds[0].Type == "rss" {
+			t.Log("\tShould resolve relative links.", checkMark)
+		} else {
+			t.Error("\tShould resolve relative links.", ballotX, feeds[0].URI, feeds[0].Type)
+		}
+
+		if feeds[1].URI == "https://other.example.com/atom.xml" && feeds[1].Type == "atom" {
+			t.Log("\tShould detect atom feeds.", checkMark)
+		} else {
+			t.Error("\tShould detect atom feeds.", ballotX, feeds[1].URI, feeds[1].Type)
+		}
+
+		if feeds[0].Name != feeds[1].Name {
+			t.Logf("\tShould give each feed a unique name. %v %q %q", checkMark, feeds[0].Name, feeds[1].Name)
+		} else {
+			t.Error("\tShould give each feed a unique name.", ballotX, feeds[0].Name)
+		}
+
+		list := search.NewFeedList(nil)
+		for _, feed := range feeds {
+			if err := list.Add(feed); err != nil {
+				t.Fatal("\tShould add the feeds to a feed list.", ballotX, err)
+			}
+		}
+		t.Log("\tShould add the feeds to a feed list.", checkMark)
+	}
+}
+
+// TestDiscoverProbe 确认首页没有声明数据源时会尝试常见的路径，并根据内容判断类型
+func TestDiscoverProbe(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, "<html><head></head></html>")
+	})
+	mux.HandleFunc("/feed", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, rss)
+	})
+	mux.HandleFunc("/feed.xml", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, atom)
+	})
+	mux.HandleFunc("/rss", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "<html><body>not a feed</body></html>")
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	t.Log("Given the need to probe common feed paths.")
+	{
+		feeds, err := discover.New(time.Second).Discover(server.URL)
+		if err != nil {
+			t.Fatal("\tShould discover feeds.", ballotX, err)
+		}
+
+		if len(feeds) == 2 && feeds[0].Type == "rss" && feeds[1].Type == "atom" {
+			t.Log("\tShould sniff the type of each feed.", checkMark)
+		} else {
+			t.Fatal("\tShould sniff the type of each feed.", ballotX, len(feeds))
+		}
+
+		if feeds[0].Name != feeds[1].Name {
+			t.Logf("\tShould give each feed a unique name. %v %q %q", checkMark, feeds[0].Name, feeds[1].Name)
+		} else {
+			t.Error("\tShould give each feed a unique name.", ballotX, feeds[0].Name)
+		}
+
+		// 找到的 Atom 数据源必须有匹配器可以解码
+		decoder, ok := search.Lookup(feeds[1].Type).(search.Decoder)
+		if !ok {
+			t.Fatal("\tShould have a decoder for atom feeds.", ballotX)
+		}
+		items, err := search.Retrieve(feeds[1])
+		if err == nil && len(items) == 1 && items[0].Link == "http://example.com/concurrency" && !items[0].Published.IsZero() {
+			t.Log("\tShould decode the discovered atom feed.", checkMark)
+		} else {
+			t.Error("\tShould decode the discovered atom feed.", ballotX, decoder, err, len(items))
+		}
+
+		results := search.Find(feeds[1:], "goroutines", nil)
+		if len(results) == 1 && results[0].Field == "Description" {
+			t.Log("\tShould search the discovered atom feed.", checkMark)
+		} else {
+			t.Error("\tShould search the discovered atom feed.", ballotX, len(results))
+		}
+	}
+
+	t.Log("Given the need to report a site without feeds.")
+	{
+		server := httptest.NewServer(http.NotFoundHandler())
+		defer server.Close()
+
+		if _, err := discover.New(time.Second).Discover(server.URL); err != nil {
+			t.Log("\tShould return an error.", checkMark, err)
+		} else {
+			t.Error("\tShould return an error.", ballotX)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+// 这个示例程序查找指定网站提供的数据源，并以数据源列表的 JSON 格式输出，方便追加到 data/data.json 里
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"notes.goinaction/chapter02/discover"
+	"notes.goinaction/chapter02/search"
+)
+
+// timeout 是每个请求的超时时间
+var timeout = flag.Duration("timeout", 10*time.Second, "每个请求的超时时间")
+
+// main 程序入口
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] site...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	d := discover.New(*timeout)
+
+	// 某个网站查找失败时只记录日志，继续查找其他网站
+	feeds := []*search.Feed{}
+	for _, site := range flag.Args() {
+		found, err := d.Discover(site)
+		if err != nil {
+			log.Println(site, err)
+			continue
+		}
+		feeds = append(feeds, found...)
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "    ")
+	if err := enc.Encode(feeds); err != nil {
+		log.Fatalln(err)
+	}
+
+	if len(feeds) == 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,324 @@
+// handlers 包为搜索程序提供网页和 JSON 接口
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"html/template"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"notes.goinaction/chapter02/search"
+)
+
+// feedList 是所有处理函数共享的数据源列表，由 Routes 设置
+var feedList *search.FeedList
+
+// index 是搜索首页的模板
+var index = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Feed Search</title>
+</head>
+<body>
+<form action="/" method="get">
+	<input type="text" name="q" value="{{.Query}}" placeholder="search term">
+	<input type="submit" value="Search">
+</form>
+{{if .Error}}<p>{{.Error}}</p>{{end}}
+{{if .Query}}<p>{{len .Results}} results</p>{{end}}
+<ul>
+{{range .Results}}
+	<li>
+		{{if .Feed}}<b>{{.Feed.Name}}</b> {{end}}{{.Field}}:
+		{{if .Item}}<a href="{{.Item.Link}}">{{.Content}}</a>{{else}}{{.Content}}{{end}}
+	</li>
+{{end}}
+</ul>
+</body>
+</html>
+`))
+
+// Routes 为网络服务设置路由，所有处理函数都使用 feeds 作为数据源列表
+func Routes(feeds *search.FeedList) {
+	feedList = feeds
+
+	http.HandleFunc("/", Index)
+	http.HandleFunc("/search", Search)
+	http.HandleFunc("/feeds", Feeds)
+	http.HandleFunc("/feeds/", Feeds)
+}
+
+// Index 返回搜索页面，请求里带有 q 参数时，在页面里显示搜索结果
+func Index(rw http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(rw, r)
+		return
+	}
+
+	page := struct {
+		Query   string
+		Results []*search.Result
+		Error   string
+	}{
+		Query: r.FormValue("q"),
+	}
+
+	status := http.StatusOK
+	if page.Query != "" {
+		opts, err := searchOptions(r)
+		if err == nil {
+			_, err = regexp.Compile(page.Query)
+		}
+		if err != nil {
+			status = http.StatusBadRequest
+			page.Error = err.Error()
+		} else {
+			page.Results = search.Find(feedList.All(), page.Query, opts)
+		}
+	}
+
+	rw.Header().Set("content-type", "text/html; charset=utf-8")
+	rw.WriteHeader(status)
+
+	index.Execute(rw, &page)
+}
+
+// Search 执行搜索并以 JSON 格式返回结果
+func Search(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		sendError(rw, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+
+	query := r.FormValue("q")
+	if query == "" {
+		sendError(rw, http.StatusBadRequest, errors.New("missing q parameter"))
+		return
+	}
+
+	// 搜索项是正则表达式，无效的表达式会在每个匹配器里失败，而匹配器的错误只会被记录在日志里
+	if _, err := regexp.Compile(query); err != nil {
+		sendError(rw, http.StatusBadRequest, err)
+		return
+	}
+
+	opts, err := searchOptions(r)
+	if err != nil {
+		sendError(rw, http.StatusBadRequest, err)
+		return
+	}
+
+	// 结果里的数据源只输出可以公开的字段
+	found := search.Find(feedList.All(), query, opts)
+	results := make([]*search.Result, len(found))
+	for i, result := range found {
+		results[i] = result.Public()
+	}
+
+	sendJSON(rw, http.StatusOK, struct {
+		Query   string           `json:"query"`
+		Count   int              `json:"count"`
+		Results []*search.Result `json:"results"`
+	}{
+		Query:   query,
+		Count:   len(results),
+		Results: results,
+	})
+}
+
+/*
+Feeds 管理数据源列表
+
+	GET    /feeds         返回全部数据源
+	POST   /feeds         添加一个数据源
+	GET    /feeds/{site}  返回一个数据源
+	PUT    /feeds/{site}  修改一个数据源
+	DELETE /feeds/{site}  删除一个数据源
+
+数据源的头部、认证、代理和 TLS 设置只能在数据源文件里配置：响应里不包含这些字段，请求里设置了这些字段时返回 400，
+修改数据源时保留原来的设置。
+*/
+func Feeds(rw http.ResponseWriter, r *http.Request) {
+	name := strings.Trim(strings.TrimPrefix(r.URL.Path, "/feeds"), "/")
+
+	switch {
+	case name == "" && r.Method == "GET":
+		feeds := feedList.All()
+		for i, feed := range feeds {
+			feeds[i] = feed.Public()
+		}
+		sendJSON(rw, http.StatusOK, feeds)
+
+	case name == "" && r.Method == "POST":
+		feed, err := decodeFeed(r)
+		if err != nil {
+			sendError(rw, http.StatusBadRequest, err)
+			return
+		}
+		if err := feedList.Add(feed); err != nil {
+			sendError(rw, statusOf(err), err)
+			return
+		}
+		sendJSON(rw, http.StatusCreated, feed.Public())
+
+	case name != "" && r.Method == "GET":
+		feed, err := feedList.Get(name)
+		if err != nil {
+			sendError(rw, statusOf(err), err)
+			return
+		}
+		sendJSON(rw, http.StatusOK, feed.Public())
+
+	case name != "" && r.Method == "PUT":
+		feed, err := decodeFeed(r)
+		if err != nil {
+			sendError(rw, http.StatusBadRequest, err)
+			return
+		}
+		old, err := feedList.Get(name)
+		if err != nil {
+			sendError(rw, statusOf(err), err)
+			return
+		}
+		// 保留数据源文件里配置的请求设置
+		feed.Headers, feed.Auth, feed.Proxy, feed.TLS = old.Headers, old.Auth, old.Proxy, old.TLS
+		if err := feedList.Update(name, feed); err != nil {
+			sendError(rw, statusOf(err), err)
+			return
+		}
+		sendJSON(rw, http.StatusOK, feed.Public())
+
+	case name != "" && r.Method == "DELETE":
+		if err := feedList.Delete(name); err != nil {
+			sendError(rw, statusOf(err), err)
+			return
+		}
+		rw.WriteHeader(http.StatusNoContent)
+
+	default:
+		sendError(rw, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+	}
+}
+
+/*
+searchOptions 从请求参数里读取搜索条件
+
+	since、until             发布时间范围
+	bbox，或 near 和 radius  地理区域
+	tag、type、site          挑选数据源，可以重复出现或使用逗号分隔
+	exclude_tag、exclude_type、exclude_site  排除数据源
+*/
+func searchOptions(r *http.Request) (*search.Options, error) {
+	var opts search.Options
+	var err error
+
+	if err := r.ParseForm(); err != nil {
+		return nil, err
+	}
+	opts.Feeds = &search.FeedFilter{
+		Tags:         formList(r, "tag"),
+		Types:        formList(r, "type"),
+		Names:        formList(r, "site"),
+		ExcludeTags:  formList(r, "exclude_tag"),
+		ExcludeTypes: formList(r, "exclude_type"),
+		ExcludeNames: formList(r, "exclude_site"),
+	}
+
+	if v := r.FormValue("since"); v != "" {
+		if opts.Since, err = search.ParseDate(v); err != nil {
+			return nil, err
+		}
+	}
+	if v := r.FormValue("until"); v != "" {
+		if opts.Until, err = search.ParseDate(v); err != nil {
+			return nil, err
+		}
+	}
+
+	if v := r.FormValue("bbox"); v != "" {
+		box, err := search.ParseBoundingBox(v)
+		if err != nil {
+			return nil, err
+		}
+		opts.Area = *box
+	}
+
+	if v := r.FormValue("near"); v != "" {
+		center, err := search.ParsePoint(v)
+		if err != nil {
+			return nil, err
+		}
+		radius, err := strconv.ParseFloat(r.FormValue("radius"), 64)
+		if err != nil || radius <= 0 {
+			return nil, errors.New("near requires a positive radius")
+		}
+		opts.Area = search.Radius{Center: *center, Km: radius}
+	}
+
+	return &opts, nil
+}
+
+// formList 读取一个可以重复出现、也可以使用逗号分隔的请求参数
+func formList(r *http.Request, key string) []string {
+	var list []string
+	for _, value := range r.Form[key] {
+		for _, v := range strings.Split(value, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				list = append(list, v)
+			}
+		}
+	}
+	return list
+}
+
+// decodeFeed 从请求体里解码一个数据源，名字和地址不能为空，也不能设置请求设置
+func decodeFeed(r *http.Request) (*search.Feed, error) {
+	var feed search.Feed
+	if err := json.NewDecoder(r.Body).Decode(&feed); err != nil {
+		return nil, err
+	}
+
+	if feed.Name == "" || feed.URI == "" {
+		return nil, errors.New("site and link are required")
+	}
+
+	// 代理和证书文件是服务器上的设置，不能由客户端指定
+	if feed.HasRequestSettings() {
+		return nil, errors.New("headers, auth, proxy and tls can only be set in the feed file")
+	}
+
+	return &feed, nil
+}
+
+// statusOf 将数据源列表返回的错误对应到 HTTP 状态码
+func statusOf(err error) int {
+	switch err {
+	case search.ErrFeedNotFound:
+		return http.StatusNotFound
+	case search.ErrFeedExists:
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// sendJSON 以 JSON 格式返回 v
+func sendJSON(rw http.ResponseWriter, status int, v interface{}) {
+	rw.Header().Set("content-type", "application/json")
+	rw.WriteHeader(status)
+
+	json.NewEncoder(rw).Encode(v)
+}
+
+// sendError 以 JSON 格式返回错误信息
+func sendError(rw http.ResponseWriter, status int, err error) {
+	sendJSON(rw, status, struct {
+		Error string `json:"error"`
+	}{
+		Error: err.Error(),
+	})
+}
@@ -0,0 +1,213 @@
+// 这个示例程序测试搜索程序的网页和 JSON 接口
+package handlers_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"notes.goinaction/chapter02/endpoint/handlers"
+	_ "notes.goinaction/chapter02/matchers"
+	"notes.goinaction/chapter02/search"
+)
+
+const checkMark = "\u2713"
+const ballotX = "\u2717"
+
+// feed 模仿了数据源返回的 rss 文档
+var feed = `<?xml version="1.0" encoding="UTF-8"?>
+<rss>
+<channel>
+	<title>Going Go Programming</title>
+	<item>
+		<pubDate>Sun, 15 Mar 2015 15:04:00 +0000</pubDate>
+		<title>Object Oriented Programming Mechanics</title>
+		<description>Go is an object oriented language.</description>
+		<link>http://www.goinggo.net/2015/03/object-oriented</link>
+	</item>
+	<item>
+		<pubDate>Mon, 16 Mar 2015 15:04:00 +0000</pubDate>
+		<title>Concurrency Patterns</title>
+		<description>Channels and goroutines.</description>
+		<link>http://www.goinggo.net/2015/03/concurrency</link>
+	</item>
+</channel>
+</rss>`
+
+// secret 是数据源请求头里的令牌，不能出现在任何响应里
+const secret = "secret-token"
+
+// 路由使用的数据源指向模仿的服务器，这样测试就不需要访问外部网络
+func init() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("content-type", "application/xml")
+		w.WriteHeader(200)
+		fmt.Fprintln(w, feed)
+	}))
+
+	handlers.Routes(search.NewFeedList([]*search.Feed{
+		{Name: "goinggo", URI: server.URL, Type: "rss", Headers: map[string]string{"X-Api-Key": secret}},
+	}))
+}
+
+// serve 通过默认的多路选择器执行一次请求
+func serve(t *testing.T, method, url, body string) *httptest.ResponseRecorder {
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal("\tShould be able to create a request.", ballotX, err)
+	}
+
+	rw := httptest.NewRecorder()
+	http.DefaultServeMux.ServeHTTP(rw, req)
+
+	return rw
+}
+
+// TestSearch 测试 /search 内部服务端点
+func TestSearch(t *testing.T) {
+	t.Log("Given the need to test the Search endpoint.")
+	{
+		rw := serve(t, "GET", "/search?q=Programming", "")
+		if rw.Code != 200 {
+			t.Fatal("\tShould receive \"200\"", ballotX, rw.Code)
+		}
+		t.Log("\tShould receive \"200\"", checkMark)
+
+		var resp struct {
+			Count   int
+			Results []*search.Result
+		}
+		if err := json.NewDecoder(rw.Body).Decode(&resp); err != nil {
+			t.Fatal("\tShould decode the response.", ballotX, err)
+		}
+		t.Log("\tShould decode the response.", checkMark)
+
+		if resp.Count == 1 && len(resp.Results) == 1 {
+			t.Log("\tShould have one result.", checkMark)
+		} else {
+			t.Fatal("\tShould have one result.", ballotX, resp.Count)
+		}
+
+		if r := resp.Results[0]; r.Feed != nil && r.Feed.Name == "goinggo" && r.Item != nil {
+			t.Log("\tShould have the feed and item of the result.", checkMark)
+		} else {
+			t.Error("\tShould have the feed and item of the result.", ballotX)
+		}
+
+		if r := resp.Results[0]; r.Feed != nil && r.Feed.Headers == nil {
+			t.Log("\tShould not expose the request headers of the feed.", checkMark)
+		} else {
+			t.Error("\tShould not expose the request headers of the feed.", ballotX)
+		}
+
+		rw = serve(t, "GET", "/search?q=Go&since=2015-03-16", "")
+		if err := json.NewDecoder(rw.Body).Decode(&resp); err != nil {
+			t.Fatal("\tShould decode the response.", ballotX, err)
+		}
+		if resp.Count == 0 {
+			t.Log("\tShould skip items published before since.", checkMark)
+		} else {
+			t.Error("\tShould skip items published before since.", ballotX, resp.Count)
+		}
+
+		rw = serve(t, "GET", "/search", "")
+		if rw.Code == 400 {
+			t.Log("\tShould receive \"400\" without a search term.", checkMark)
+		} else {
+			t.Error("\tShould receive \"400\" without a search term.", ballotX, rw.Code)
+		}
+
+		rw = serve(t, "GET", "/search?q=(", "")
+		var errResp struct {
+			Error string
+		}
+		json.NewDecoder(rw.Body).Decode(&errResp)
+		if rw.Code == 400 && strings.Contains(errResp.Error, "missing closing )") {
+			t.Log("\tShould receive \"400\" with an invalid regular expression.", checkMark)
+		} else {
+			t.Error("\tShould receive \"400\" with an invalid regular expression.", ballotX, rw.Code, errResp.Error)
+		}
+	}
+}
+
+// TestFeeds 测试 /feeds 内部服务端点的增删改查
+func TestFeeds(t *testing.T) {
+	var steps = []struct {
+		method     string
+		url        string
+		body       string
+		statusCode int
+	}{
+		{"POST", "/feeds", `{"site":"cnn","link":"http://rss.cnn.com/rss/cnn_world.rss","type":"rss"}`, http.StatusCreated},
+		{"POST", "/feeds", `{"site":"cnn","link":"http://rss.cnn.com/rss/cnn_world.rss","type":"rss"}`, http.StatusConflict},
+		{"POST", "/feeds", `{"site":"cnn"}`, http.StatusBadRequest},
+		{"POST", "/feeds", `{"site":"evil","link":"http://example.com/rss","type":"rss","proxy":"http://attacker:8080"}`, http.StatusBadRequest},
+		{"POST", "/feeds", `{"site":"evil","link":"http://example.com/rss","type":"rss","tls":{"key_file":"/etc/ssl/private/server.key"}}`, http.StatusBadRequest},
+		{"PUT", "/feeds/goinggo", `{"site":"goinggo","link":"http://example.com/rss","headers":{"X-Api-Key":"other"}}`, http.StatusBadRequest},
+		{"GET", "/feeds/cnn", "", http.StatusOK},
+		{"PUT", "/feeds/cnn", `{"site":"cnn","link":"http://rss.cnn.com/rss/cnn_topstories.rss","type":"rss"}`, http.StatusOK},
+		{"DELETE", "/feeds/cnn", "", http.StatusNoContent},
+		{"GET", "/feeds/cnn", "", http.StatusNotFound},
+		{"PATCH", "/feeds", "", http.StatusMethodNotAllowed},
+	}
+
+	t.Log("Given the need to test the Feeds endpoint.")
+	{
+		for _, s := range steps {
+			t.Logf("\tWhen sending \"%s %s\"", s.method, s.url)
+			{
+				rw := serve(t, s.method, s.url, s.body)
+				if rw.Code == s.statusCode {
+					t.Logf("\t\tShould receive \"%d\" %v", s.statusCode, checkMark)
+				} else {
+					t.Errorf("\t\tShould receive \"%d\" %v %v", s.statusCode, ballotX, rw.Code)
+				}
+			}
+		}
+
+		rw := serve(t, "GET", "/feeds", "")
+		if !strings.Contains(rw.Body.String(), secret) && !strings.Contains(rw.Body.String(), "headers") {
+			t.Log("\tShould not expose the request headers of the feeds.", checkMark)
+		} else {
+			t.Error("\tShould not expose the request headers of the feeds.", ballotX, rw.Body.String())
+		}
+
+		var feeds []*search.Feed
+		if err := json.NewDecoder(rw.Body).Decode(&feeds); err != nil {
+			t.Fatal("\tShould decode the feed list.", ballotX, err)
+		}
+		if len(feeds) == 1 && feeds[0].Name == "goinggo" {
+			t.Log("\tShould have only the original feed left.", checkMark)
+		} else {
+			t.Error("\tShould have only the original feed left.", ballotX, len(feeds))
+		}
+	}
+}
+
+// TestIndex 测试搜索页面
+func TestIndex(t *testing.T) {
+	t.Log("Given the need to test the search page.")
+	{
+		rw := serve(t, "GET", "/?q=Concurrency", "")
+		if rw.Code != 200 {
+			t.Fatal("\tShould receive \"200\"", ballotX, rw.Code)
+		}
+		t.Log("\tShould receive \"200\"", checkMark)
+
+		if strings.Contains(rw.Body.String(), "http://www.goinggo.net/2015/03/concurrency") {
+			t.Log("\tShould render the result link.", checkMark)
+		} else {
+			t.Error("\tShould render the result link.", ballotX)
+		}
+
+		rw = serve(t, "GET", "/?q=(", "")
+		if rw.Code == 400 && strings.Contains(rw.Body.String(), "missing closing )") {
+			t.Log("\tShould show the error of an invalid regular expression.", checkMark)
+		} else {
+			t.Error("\tShould show the error of an invalid regular expression.", ballotX, rw.Code)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+// 这个示例程序为搜索程序提供网页和 JSON 接口
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+
+	"notes.goinaction/chapter02/endpoint/handlers"
+	_ "notes.goinaction/chapter02/matchers"
+	"notes.goinaction/chapter02/search"
+)
+
+// 命令行参数
+var (
+	addr     = flag.String("addr", ":4000", "监听的地址")
+	dataFile = flag.String("data", "data/data.json", "数据源列表文件，通过接口做的修改会保存回这个文件")
+
+	loadCredentials = search.DefaultFetcher.CredentialsFlag(flag.CommandLine)
+)
+
+// main 应用程序入口
+func main() {
+	flag.Parse()
+
+	if err := loadCredentials(); err != nil {
+		log.Fatalln(err)
+	}
+
+	feeds, err := search.LoadFeedList(*dataFile)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	handlers.Routes(feeds)
+
+	log.Println("listener : Started : Listening on", *addr)
+	log.Fatalln(http.ListenAndServe(*addr, nil))
+}
@@ -0,0 +1,141 @@
+// Package health 包检查数据源是否可以正常访问和解码
+package health
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"notes.goinaction/chapter02/search"
+)
+
+// Report 保存对一个数据源的检查结果
+type Report struct {
+	Site        string        `json:"site"`
+	URI         string        `json:"link"`
+	Type        string        `json:"type"`
+	StatusCode  int           `json:"status_code"`
+	Latency     time.Duration `json:"latency"`
+	Redirects   []string      `json:"redirects,omitempty"`
+	ContentType string        `json:"content_type"`
+	Items       int           `json:"items"`
+	Newest      time.Time     `json:"newest"`
+	Error       string        `json:"error,omitempty"`
+}
+
+// OK 报告数据源是否健康：能够访问、状态码为 200 并且可以正确解码
+func (r *Report) OK() bool {
+	return r.Error == "" && r.StatusCode == http.StatusOK
+}
+
+// Checker 使用 search.DefaultFetcher 按照数据源的设置检查数据源
+type Checker struct {
+	timeout time.Duration
+}
+
+// New 返回一个请求超时时间为 timeout 的 Checker
+func New(timeout time.Duration) *Checker {
+	return &Checker{
+		timeout: timeout,
+	}
+}
+
+// CheckAll 并发地检查所有数据源，返回的报告与 feeds 的顺序一致
+func (c *Checker) CheckAll(feeds []*search.Feed) []*Report {
+	reports := make([]*Report, len(feeds))
+
+	var wg sync.WaitGroup
+	wg.Add(len(feeds))
+
+	// 每个 goroutine 只写入自己下标的位置，所以不需要加锁
+	for i, feed := range feeds {
+		go func(i int, feed *search.Feed) {
+			reports[i] = c.Check(feed)
+			wg.Done()
+		}(i, feed)
+	}
+
+	wg.Wait()
+
+	return reports
+}
+
+// Check 请求一个数据源，记录响应的状态并尝试解码其中的条目
+func (c *Checker) Check(feed *search.Feed) *Report {
+	report := Report{
+		Site: feed.Name,
+		URI:  feed.URI,
+		Type: feed.Type,
+	}
+
+	if err := c.check(feed, &report); err != nil {
+		report.Error = err.Error()
+	}
+
+	return &report
+}
+
+// check 完成实际的检查工作，遇到的第一个错误会被返回并记录在报告里
+func (c *Checker) check(feed *search.Feed, report *Report) error {
+	if feed.URI == "" {
+		return errors.New("no feed URI provided")
+	}
+
+	req, err := search.DefaultFetcher.NewRequest(feed)
+	if err != nil {
+		return err
+	}
+
+	shared, err := search.DefaultFetcher.ClientFor(feed)
+	if err != nil {
+		return err
+	}
+
+	// 复制一份客户端，以便记录这一次请求经过的重定向，而不影响其他 goroutine
+	client := *shared
+	client.Timeout = c.timeout
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		report.Redirects = append(report.Redirects, req.URL.String())
+		if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+		return nil
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	report.Latency = time.Since(start)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	report.StatusCode = resp.StatusCode
+	report.ContentType = resp.Header.Get("Content-Type")
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("HTTP Response Error %d", resp.StatusCode)
+	}
+
+	// 只有实现了 Decoder 接口的匹配器才能检查数据源的内容
+	decoder, ok := search.Lookup(feed.Type).(search.Decoder)
+	if !ok {
+		return fmt.Errorf("no decoder for feed type %q", feed.Type)
+	}
+
+	items, err := decoder.Decode(resp.Body)
+	if err != nil {
+		return fmt.Errorf("decode: %v", err)
+	}
+
+	report.Items = len(items)
+	for _, item := range items {
+		if item.Published.After(report.Newest) {
+			report.Newest = item.Published
+		}
+	}
+
+	return nil
+}
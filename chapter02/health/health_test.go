@@ -0,0 +1,123 @@
+// 这个示例程序使用模仿的服务器测试数据源的健康检查
+package health_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"notes.goinaction/chapter02/health"
+	_ "notes.goinaction/chapter02/matchers"
+	"notes.goinaction/chapter02/search"
+)
+
+const checkMark = "\u2713"
+const ballotX = "\u2717"
+
+// feed 模仿了数据源返回的 rss 文档，第二个条目是最新的
+var feed = `<?xml version="1.0" encoding="UTF-8"?>
+<rss>
+<channel>
+	<title>Going Go Programming</title>
+	<item>
+		<pubDate>Sun, 15 Mar 2015 15:04:00 +0000</pubDate>
+		<title>Object Oriented Programming Mechanics</title>
+	</item>
+	<item>
+		<pubDate>Mon, 16 Mar 2015 15:04:00 +0000</pubDate>
+		<title>Concurrency Patterns</title>
+	</item>
+</channel>
+</rss>`
+
+// mockServer 返回一个模仿数据源的服务器：/feed 返回 rss 文档，/moved 重定向到 /feed，
+// /missing 返回 404，/broken 返回无法解码的内容
+func mockServer() *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/feed", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("content-type", "application/xml")
+		fmt.Fprintln(w, feed)
+	})
+	mux.Handle("/moved", http.RedirectHandler("/feed", http.StatusMovedPermanently))
+	mux.HandleFunc("/missing", http.NotFound)
+	mux.HandleFunc("/broken", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "<rss><channel><item>")
+	})
+
+	return httptest.NewServer(mux)
+}
+
+// TestCheck 确认健康报告记录了状态码、重定向、条目数量和最新的发布时间
+func TestCheck(t *testing.T) {
+	server := mockServer()
+	defer server.Close()
+
+	newest := time.Date(2015, 3, 16, 15, 4, 0, 0, time.UTC)
+
+	var checks = []struct {
+		path      string
+		ok        bool
+		status    int
+		redirects int
+		items     int
+	}{
+		{"/feed", true, http.StatusOK, 0, 2},
+		{"/moved", true, http.StatusOK, 1, 2},
+		{"/missing", false, http.StatusNotFound, 0, 0},
+		{"/broken", false, http.StatusOK, 0, 0},
+	}
+
+	feeds := make([]*search.Feed, len(checks))
+	for i, c := range checks {
+		feeds[i] = &search.Feed{Name: c.path, URI: server.URL + c.path, Type: "rss"}
+	}
+
+	t.Log("Given the need to check the health of feeds.")
+	{
+		reports := health.New(time.Second).CheckAll(feeds)
+
+		for i, c := range checks {
+			r := reports[i]
+			t.Logf("\tWhen checking %q", c.path)
+			{
+				if r.Site == c.path {
+					t.Log("\t\tShould keep the order of the feeds.", checkMark)
+				} else {
+					t.Error("\t\tShould keep the order of the feeds.", ballotX, r.Site)
+				}
+
+				if r.OK() == c.ok && r.StatusCode == c.status {
+					t.Logf("\t\tShould report ok=%v with status %d %v", c.ok, c.status, checkMark)
+				} else {
+					t.Errorf("\t\tShould report ok=%v with status %d %v %d %s", c.ok, c.status, ballotX, r.StatusCode, r.Error)
+				}
+
+				if len(r.Redirects) == c.redirects {
+					t.Logf("\t\tShould record %d redirects %v", c.redirects, checkMark)
+				} else {
+					t.Errorf("\t\tShould record %d redirects %v %v", c.redirects, ballotX, r.Redirects)
+				}
+
+				if r.Items == c.items {
+					t.Logf("\t\tShould count %d items %v", c.items, checkMark)
+				} else {
+					t.Errorf("\t\tShould count %d items %v %d", c.items, ballotX, r.Items)
+				}
+
+				if !c.ok || r.Newest.Equal(newest) {
+					t.Log("\t\tShould find the newest item.", checkMark)
+				} else {
+					t.Error("\t\tShould find the newest item.", ballotX, r.Newest)
+				}
+			}
+		}
+
+		if r := health.New(time.Second).Check(&search.Feed{Name: "empty", Type: "rss"}); !r.OK() && r.Error != "" {
+			t.Log("\tShould report a feed without a link.", checkMark)
+		} else {
+			t.Error("\tShould report a feed without a link.", ballotX)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+// 这个示例程序检查数据源列表里的每个数据源，并输出健康报告。存在不健康的数据源时以状态码 1 退出
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"notes.goinaction/chapter02/health"
+	_ "notes.goinaction/chapter02/matchers"
+	"notes.goinaction/chapter02/search"
+)
+
+// 命令行参数
+var (
+	asJSON  = flag.Bool("json", false, "以 JSON 格式输出报告")
+	timeout = flag.Duration("timeout", 10*time.Second, "每个数据源的请求超时时间")
+
+	loadCredentials = search.DefaultFetcher.CredentialsFlag(flag.CommandLine)
+)
+
+// main 程序入口
+func main() {
+	flag.Parse()
+
+	if err := loadCredentials(); err != nil {
+		log.Fatalln(err)
+	}
+
+	feeds, err := search.RetrieveFeeds()
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	reports := health.New(*timeout).CheckAll(feeds)
+
+	if *asJSON {
+		err = writeJSON(reports)
+	} else {
+		err = writeTable(reports)
+	}
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	for _, report := range reports {
+		if !report.OK() {
+			os.Exit(1)
+		}
+	}
+}
+
+// writeJSON 将报告以 JSON 格式写到标准输出
+func writeJSON(reports []*health.Report) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "    ")
+	return enc.Encode(reports)
+}
+
+// writeTable 将报告以对齐的表格写到标准输出
+func writeTable(reports []*health.Report) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "SITE\tSTATUS\tLATENCY\tITEMS\tNEWEST\tCONTENT-TYPE\tREDIRECTS\tERROR")
+
+	for _, r := range reports {
+		newest := "-"
+		if !r.Newest.IsZero() {
+			newest = r.Newest.Format(time.RFC3339)
+		}
+
+		fmt.Fprintf(w, "%s\t%d\t%v\t%d\t%s\t%s\t%s\t%s\n",
+			r.Site,
+			r.StatusCode,
+			r.Latency.Round(time.Millisecond),
+			r.Items,
+			newest,
+			orDash(r.ContentType),
+			orDash(strings.Join(r.Redirects, " -> ")),
+			orDash(r.Error),
+		)
+	}
+
+	return w.Flush()
+}
+
+// orDash 将空字符串显示成 "-"，保持表格的列对齐
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
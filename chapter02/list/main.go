@@ -0,0 +1,39 @@
+// 这个示例程序按照标签分组列出数据源列表里的数据源
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+
+	"notes.goinaction/chapter02/search"
+)
+
+// dataFile 是数据源列表文件
+var dataFile = flag.String("data", "data/data.json", "数据源列表文件")
+
+// main 程序入口
+func main() {
+	flag.Parse()
+
+	list, err := search.LoadFeedList(*dataFile)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	groups := search.GroupByTag(list.All())
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for _, tag := range search.Tags(groups) {
+		fmt.Fprintf(w, "%s (%d)\n", tag, len(groups[tag]))
+		for _, feed := range groups[tag] {
+			fmt.Fprintf(w, "\t%s\t%s\t%s\n", feed.Name, feed.Type, feed.URI)
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		log.Fatalln(err)
+	}
+}
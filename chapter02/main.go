@@ -19,8 +19,12 @@ package main
 */
 import (
 	/* 从标准库中导入代码时，只需要给出要导入的包名。*/
+	"errors"
+	"flag"
 	"log"
 	"os"
+	"strings"
+	"time"
 
 	/*
 		1. 导入第三方包时，需给出 GOPATH/src 目录下的路径信息。
@@ -34,6 +38,27 @@ import (
 	"notes.goinaction/chapter02/search"
 )
 
+// 命令行参数
+var (
+	term   = flag.String("q", "president", "要搜索的内容，支持正则表达式")
+	near   = flag.String("near", "", "只搜索这个坐标附近的条目，格式为 \"lat,lon\"，需要和 -radius 一起使用")
+	radius = flag.Float64("radius", 0, "-near 的搜索半径，单位为公里")
+	bbox   = flag.String("bbox", "", "只搜索这个矩形区域内的条目，格式为 \"minLat,minLon,maxLat,maxLon\"")
+	since  = flag.String("since", "", "只搜索这个时间之后发布的条目，可以是日期（如 2021-04-17）或者距今的时长（如 48h）")
+	until  = flag.String("until", "", "只搜索这个时间之前发布的条目，格式同 -since")
+
+	tags         = flag.String("tag", "", "只搜索带有这些标签的数据源，多个标签使用逗号分隔")
+	types        = flag.String("type", "", "只搜索这些类型的数据源，多个类型使用逗号分隔")
+	sites        = flag.String("site", "", "只搜索名字匹配这些通配符的数据源，如 \"cnn*\"，多个通配符使用逗号分隔")
+	excludeTags  = flag.String("exclude-tag", "", "排除带有这些标签的数据源")
+	excludeTypes = flag.String("exclude-type", "", "排除这些类型的数据源")
+	excludeSites = flag.String("exclude-site", "", "排除名字匹配这些通配符的数据源")
+
+	cluster = flag.Bool("cluster", false, "合并多个数据源里近似重复的结果，每组只显示一个代表结果和转载它的数据源")
+
+	loadCredentials = search.DefaultFetcher.CredentialsFlag(flag.CommandLine)
+)
+
 // init 程序里所有被编译器发现的 init 函数都会安排在 main 函数之前执行
 func init() {
 	// 将日志输出到标准输出
@@ -47,6 +72,17 @@ main 整个程序的入口
 2. main 函数保存在名为 main 的包里。如果 main 函数不在 main 包里，构建工具就不会生成可执行的文件。
 */
 func main() {
+	flag.Parse()
+
+	if err := loadCredentials(); err != nil {
+		log.Fatalln(err)
+	}
+
+	opts, err := options()
+	if err != nil {
+		log.Fatalln(err)
+	}
+
 	/*
 		调用 search 包里的 Run 函数
 
@@ -54,5 +90,75 @@ func main() {
 		直接访问这个包中任意一个公开的标识符。这些标识符以大写字母开头。以小写字母开头的标识符
 		是不公开的，不能被其他包中的代码直接访问。
 	*/
-	search.Run("president")
+	search.Run(*term, opts)
+}
+
+// options 根据命令行参数构造搜索条件
+func options() (*search.Options, error) {
+	opts := search.Options{Cluster: *cluster}
+
+	switch {
+	case *near != "" && *bbox != "":
+		return nil, errors.New("-near and -bbox can not be used together")
+
+	case *near != "":
+		center, err := search.ParsePoint(*near)
+		if err != nil {
+			return nil, err
+		}
+		if *radius <= 0 {
+			return nil, errors.New("-near requires a positive -radius")
+		}
+		opts.Area = search.Radius{Center: *center, Km: *radius}
+
+	case *bbox != "":
+		box, err := search.ParseBoundingBox(*bbox)
+		if err != nil {
+			return nil, err
+		}
+		opts.Area = *box
+	}
+
+	opts.Feeds = &search.FeedFilter{
+		Tags:         splitList(*tags),
+		Types:        splitList(*types),
+		Names:        splitList(*sites),
+		ExcludeTags:  splitList(*excludeTags),
+		ExcludeTypes: splitList(*excludeTypes),
+		ExcludeNames: splitList(*excludeSites),
+	}
+
+	var err error
+	if opts.Since, err = parseTime(*since); err != nil {
+		return nil, err
+	}
+	if opts.Until, err = parseTime(*until); err != nil {
+		return nil, err
+	}
+
+	return &opts, nil
+}
+
+// parseTime 解析 -since 和 -until 参数，时长表示从当前时间往前推算
+func parseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	if d, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-d), nil
+	}
+
+	return search.ParseDate(value)
+}
+
+// splitList 把逗号分隔的参数拆分成列表，忽略空白的项
+func splitList(value string) []string {
+	var list []string
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
 }
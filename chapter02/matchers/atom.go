@@ -0,0 +1,180 @@
+package matchers
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"regexp"
+	"time"
+
+	"notes.goinaction/chapter02/search"
+)
+
+type (
+	// atomLink 是 Atom 条目里的 <link> 元素，地址和关系都写在属性里
+	atomLink struct {
+		Href string `xml:"href,attr"`
+		Rel  string `xml:"rel,attr"`
+	}
+
+	// atomEntry 根据 entry 字段的标签，将定义的字段与 Atom 文档的字段关联起来
+	atomEntry struct {
+		Title       string     `xml:"title"`
+		Summary     string     `xml:"summary"`
+		Content     string     `xml:"content"`
+		Links       []atomLink `xml:"link"`
+		ID          string     `xml:"id"`
+		Published   string     `xml:"published"`
+		Updated     string     `xml:"updated"`
+		GeoRssPoint string     `xml:"point"`
+	}
+
+	// atomDocument 定义了与 Atom 文档关联的字段，和 rss 一样只写本地名，不依赖命名空间的声明
+	atomDocument struct {
+		XMLName xml.Name    `xml:"feed"`
+		Title   string      `xml:"title"`
+		Entry   []atomEntry `xml:"entry"`
+	}
+)
+
+// atomMatcher 实现了 Matcher 和 Decoder 接口
+type atomMatcher struct{}
+
+// init 将匹配器注册到程序里
+func init() {
+	var matcher atomMatcher
+	search.Register("atom", matcher)
+}
+
+// retrieve 发送 HTTP Get 请求获取 Atom 数据源并解码
+func (m atomMatcher) retrieve(feed *search.Feed) (*atomDocument, error) {
+	if feed.URI == "" {
+		return nil, errors.New("No atom feed URI provided")
+	}
+
+	resp, err := search.Get(feed)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("HTTP Response Error %d", resp.StatusCode)
+	}
+
+	return m.decode(resp.Body)
+}
+
+// decode 将 Atom 数据源文档解码到我们定义的结构类型里
+func (m atomMatcher) decode(r io.Reader) (*atomDocument, error) {
+	var document atomDocument
+	err := xml.NewDecoder(r).Decode(&document)
+
+	return &document, err
+}
+
+// Decode 实现了 search.Decoder 接口，将 Atom 文档解码成条目
+func (m atomMatcher) Decode(r io.Reader) ([]*search.Item, error) {
+	document, err := m.decode(r)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]*search.Item, 0, len(document.Entry))
+	for _, entry := range document.Entry {
+		items = append(items, entry.toItem())
+	}
+
+	return items, nil
+}
+
+// description 返回条目的摘要，没有摘要时使用正文
+func (e atomEntry) description() string {
+	if e.Summary != "" {
+		return e.Summary
+	}
+	return e.Content
+}
+
+// link 返回条目的网页地址，也就是 rel 为 alternate 或者省略 rel 的链接
+func (e atomEntry) link() string {
+	for _, l := range e.Links {
+		if l.Rel == "" || l.Rel == "alternate" {
+			return l.Href
+		}
+	}
+	return ""
+}
+
+// published 解析条目的发布时间，published 缺失时使用 updated
+func (e atomEntry) published() time.Time {
+	for _, value := range []string{e.Published, e.Updated} {
+		if value == "" {
+			continue
+		}
+		if t, err := search.ParseDate(value); err == nil {
+			return t
+		}
+	}
+
+	return time.Time{}
+}
+
+// toItem 将 Atom 条目转换成搜索条件可以检查的条目
+func (e atomEntry) toItem() *search.Item {
+	item := search.Item{
+		Title:       e.Title,
+		Description: e.description(),
+		Link:        e.link(),
+		GUID:        e.ID,
+		Published:   e.published(),
+	}
+	if e.GeoRssPoint != "" {
+		item.Point, _ = search.ParsePoint(e.GeoRssPoint)
+	}
+
+	return &item
+}
+
+// Search 在文档中查找特定的搜索项，条目的摘要或正文作为 Description 匹配
+func (m atomMatcher) Search(feed *search.Feed, searchTerm string, opts *search.Options) ([]*search.Result, error) {
+	var results []*search.Result
+	log.Printf("Search Feed Type[%s] Site[%s] For Uri[%s]\n", feed.Type, feed.Name, feed.URI)
+
+	document, err := m.retrieve(feed)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range document.Entry {
+		// 跳过不满足搜索条件的条目
+		searchItem := entry.toItem()
+		if !opts.Accept(searchItem) {
+			continue
+		}
+
+		// 依次检查标题和描述部分是否包含搜索项
+		fields := []struct{ name, content string }{
+			{"Title", searchItem.Title},
+			{"Description", searchItem.Description},
+		}
+		for _, field := range fields {
+			matched, err := regexp.MatchString(searchTerm, field.content)
+			if err != nil {
+				return nil, err
+			}
+			if matched {
+				results = append(results, &search.Result{
+					Field:   field.name,
+					Content: field.content,
+					Feed:    feed,
+					Item:    searchItem,
+				})
+			}
+		}
+	}
+
+	return results, nil
+}
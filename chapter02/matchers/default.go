@@ -30,6 +30,6 @@ Search 实现了默认匹配器的行为
 4. 使用指针作为接收者声明的方法，只能在接口类型的值是一个指针的时候被调用。使用值作为接收者声明的方法，
 在接口类型的值为值或者指针时，都可以被调用。
 */
-func (m defaultMatcher) Search(feed *search.Feed, searchTerm string) ([]*search.Result, error) {
+func (m defaultMatcher) Search(feed *search.Feed, searchTerm string, opts *search.Options) ([]*search.Result, error) {
 	return nil, nil
 }
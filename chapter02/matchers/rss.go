@@ -4,15 +4,21 @@ import (
 	"encoding/xml"
 	"errors"
 	"fmt"
+	"io"
 	"log"
-	"net/http"
 	"regexp"
+	"time"
 
 	"notes.goinaction/chapter02/search"
 )
 
 type (
-	// item 根据 item 字段的标签，将定义的字段与 rss 文档的字段关联起来
+	/*
+		item 根据 item 字段的标签，将定义的字段与 rss 文档的字段关联起来
+
+		encoding/xml 会把 georss:point 这样的前缀解析成命名空间的 URL，标签里直接写前缀是匹配不到的。
+		这里只写本地名，这样无论文档如何声明命名空间都可以解码。
+	*/
 	item struct {
 		XMLName     xml.Name `xml:"item"`
 		PubDate     string   `xml:"pubDate"`
@@ -20,7 +26,10 @@ type (
 		Description string   `xml:"description"`
 		Link        string   `xml:"link"`
 		GUID        string   `xml:"guid"`
-		GeoRssPoint string   `xml:"georss:point"`
+		DCDate      string   `xml:"date"`
+		GeoRssPoint string   `xml:"point"`
+		GeoLat      string   `xml:"lat"`
+		GeoLong     string   `xml:"long"`
 	}
 
 	// image 根据 image 字段的标签，将定义的字段与 rss 文档的字段关联起来
@@ -54,7 +63,7 @@ type (
 	}
 )
 
-// rssMatcher 实现了 Matcher 接口
+// rssMatcher 实现了 Matcher 和 Decoder 接口
 type rssMatcher struct{}
 
 // init 将匹配器注册到程序里
@@ -69,8 +78,8 @@ func (m rssMatcher) retrieve(feed *search.Feed) (*rssDocument, error) {
 		return nil, errors.New("No rss feed URI provided")
 	}
 
-	// 从网络获得 rss 数据源文档
-	resp, err := http.Get(feed.URI)
+	// 从网络获得 rss 数据源文档，使用共享的 Fetcher 以便带上数据源配置的头部、认证和代理
+	resp, err := search.Get(feed)
 	if err != nil {
 		return nil, err
 	}
@@ -83,15 +92,77 @@ func (m rssMatcher) retrieve(feed *search.Feed) (*rssDocument, error) {
 		return nil, fmt.Errorf("HTTP Response Error %d", resp.StatusCode)
 	}
 
-	// 将 rss 数据源文档解码到我们定义的结构类型里，不需要检查错误，调用者会做这件事
+	return m.decode(resp.Body)
+}
+
+// decode 将 rss 数据源文档解码到我们定义的结构类型里，不需要检查错误，调用者会做这件事
+func (m rssMatcher) decode(r io.Reader) (*rssDocument, error) {
 	var document rssDocument
-	err = xml.NewDecoder(resp.Body).Decode(&document)
+	err := xml.NewDecoder(r).Decode(&document)
 
 	return &document, err
 }
 
+// Decode 实现了 search.Decoder 接口，将 rss 文档解码成条目
+func (m rssMatcher) Decode(r io.Reader) ([]*search.Item, error) {
+	document, err := m.decode(r)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]*search.Item, 0, len(document.Channel.Item))
+	for _, channelItem := range document.Channel.Item {
+		items = append(items, channelItem.toItem())
+	}
+
+	return items, nil
+}
+
+// point 解析条目的坐标，优先使用 georss:point，其次使用 geo:lat 和 geo:long
+func (i item) point() *search.Point {
+	if i.GeoRssPoint != "" {
+		if p, err := search.ParsePoint(i.GeoRssPoint); err == nil {
+			return p
+		}
+	}
+
+	if i.GeoLat != "" && i.GeoLong != "" {
+		if p, err := search.NewPoint(i.GeoLat, i.GeoLong); err == nil {
+			return p
+		}
+	}
+
+	return nil
+}
+
+// published 解析条目的发布时间，pubDate 缺失时使用 dc:date
+func (i item) published() time.Time {
+	for _, value := range []string{i.PubDate, i.DCDate} {
+		if value == "" {
+			continue
+		}
+		if t, err := search.ParseDate(value); err == nil {
+			return t
+		}
+	}
+
+	return time.Time{}
+}
+
+// toItem 将 rss 条目转换成搜索条件可以检查的条目
+func (i item) toItem() *search.Item {
+	return &search.Item{
+		Title:       i.Title,
+		Description: i.Description,
+		Link:        i.Link,
+		GUID:        i.GUID,
+		Published:   i.published(),
+		Point:       i.point(),
+	}
+}
+
 // Search 在文档中查找特定的搜索项
-func (m rssMatcher) Search(feed *search.Feed, searchTerm string) ([]*search.Result, error) {
+func (m rssMatcher) Search(feed *search.Feed, searchTerm string, opts *search.Options) ([]*search.Result, error) {
 	var results []*search.Result
 	log.Printf("Search Feed Type[%s] Site[%s] For Uri[%s]\n", feed.Type, feed.Name, feed.URI)
 
@@ -102,6 +173,12 @@ func (m rssMatcher) Search(feed *search.Feed, searchTerm string) ([]*search.Resu
 	}
 
 	for _, channelItem := range document.Channel.Item {
+		// 跳过不满足搜索条件的条目
+		searchItem := channelItem.toItem()
+		if !opts.Accept(searchItem) {
+			continue
+		}
+
 		// 检查标题部分是否包含搜索项
 		matched, err := regexp.MatchString(searchTerm, channelItem.Title)
 		if err != nil {
@@ -112,6 +189,8 @@ func (m rssMatcher) Search(feed *search.Feed, searchTerm string) ([]*search.Resu
 			results = append(results, &search.Result{
 				Field:   "Title",
 				Content: channelItem.Title,
+				Feed:    feed,
+				Item:    searchItem,
 			})
 		}
 
@@ -125,6 +204,8 @@ func (m rssMatcher) Search(feed *search.Feed, searchTerm string) ([]*search.Resu
 			results = append(results, &search.Result{
 				Field:   "Description",
 				Content: channelItem.Description,
+				Feed:    feed,
+				Item:    searchItem,
 			})
 		}
 	}
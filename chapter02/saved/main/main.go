@@ -0,0 +1,63 @@
+// 这个示例程序按照执行计划定时执行保存的搜索，并把新出现的结果发送到指定的目的地
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+
+	_ "notes.goinaction/chapter02/matchers"
+	"notes.goinaction/chapter02/saved"
+	"notes.goinaction/chapter02/search"
+)
+
+// 命令行参数
+var (
+	dataFile     = flag.String("data", "data/data.json", "数据源列表文件")
+	searchesFile = flag.String("searches", "data/searches.json", "保存的搜索列表文件")
+	once         = flag.Bool("once", false, "立即执行所有搜索一次后退出，不按照执行计划运行")
+
+	loadCredentials = search.DefaultFetcher.CredentialsFlag(flag.CommandLine)
+)
+
+// main 程序入口
+func main() {
+	flag.Parse()
+
+	if err := loadCredentials(); err != nil {
+		log.Fatalln(err)
+	}
+
+	feeds, err := search.LoadFeedList(*dataFile)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	searches, err := saved.Load(*searchesFile)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	scheduler, err := saved.NewScheduler(feeds, searches)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	if *once {
+		if err := scheduler.RunAll(); err != nil {
+			log.Fatalln(err)
+		}
+		return
+	}
+
+	// 收到中断信号时停止调度
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	log.Printf("Scheduling %d saved searches.", len(searches))
+	if err := scheduler.Run(ctx); err != nil && err != context.Canceled {
+		log.Fatalln(err)
+	}
+}
@@ -0,0 +1,192 @@
+package saved
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/smtp"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"notes.goinaction/chapter02/search"
+)
+
+// Notification 是一次搜索发现的新结果，会被发送到搜索的每一个目的地
+type Notification struct {
+	Search  string           `json:"search"`
+	Query   string           `json:"query"`
+	Time    time.Time        `json:"time"`
+	Results []*search.Result `json:"results"`
+}
+
+// Notifier 定义了把新结果发送到某个目的地的行为
+type Notifier interface {
+	Notify(n *Notification) error
+}
+
+// NewNotifier 根据目的地的类型创建对应的 Notifier
+func NewNotifier(d Destination) (Notifier, error) {
+	switch d.Type {
+	case "stdout":
+		return &WriterNotifier{W: os.Stdout}, nil
+
+	case "file":
+		if d.Path == "" {
+			return nil, fmt.Errorf("destination %q: path is required", d.Type)
+		}
+		return &FileNotifier{Path: d.Path}, nil
+
+	case "webhook":
+		if d.URL == "" {
+			return nil, fmt.Errorf("destination %q: url is required", d.Type)
+		}
+		return &WebhookNotifier{URL: d.URL}, nil
+
+	case "smtp":
+		if d.Addr == "" || d.From == "" || len(d.To) == 0 {
+			return nil, fmt.Errorf("destination %q: addr, from and to are required", d.Type)
+		}
+		n := SMTPNotifier{Addr: d.Addr, From: d.From, To: d.To}
+		if d.Username != "" {
+			host := d.Addr
+			if i := strings.LastIndex(host, ":"); i >= 0 {
+				host = host[:i]
+			}
+			n.Auth = smtp.PlainAuth("", d.Username, os.Getenv(d.PasswordEnv), host)
+		}
+		return &n, nil
+
+	default:
+		return nil, fmt.Errorf("unknown destination type %q", d.Type)
+	}
+}
+
+// WriterNotifier 把新结果以文本形式写到 W，例如标准输出
+type WriterNotifier struct {
+	m sync.Mutex
+	W io.Writer
+}
+
+// Notify 实现了 Notifier 接口
+func (w *WriterNotifier) Notify(n *Notification) error {
+	w.m.Lock()
+	defer w.m.Unlock()
+
+	_, err := io.WriteString(w.W, formatText(n))
+	return err
+}
+
+// FileNotifier 把新结果以 JSON 的形式追加到文件里，每次通知占一行
+type FileNotifier struct {
+	m    sync.Mutex
+	Path string
+}
+
+// Notify 实现了 Notifier 接口
+func (f *FileNotifier) Notify(n *Notification) error {
+	data, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	file, err := os.OpenFile(f.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+// webhookTimeout 是 WebhookNotifier 默认的请求超时时间
+const webhookTimeout = 30 * time.Second
+
+/*
+webhookClient 是 WebhookNotifier 默认使用的客户端
+
+http.DefaultClient 没有超时时间，Scheduler 会等待每次通知完成，一个没有响应的地址会让所有保存的搜索都停下来。
+*/
+var webhookClient = &http.Client{Timeout: webhookTimeout}
+
+// WebhookNotifier 把新结果以 JSON 的形式 POST 到 URL
+type WebhookNotifier struct {
+	URL string
+
+	// Client 是发送请求使用的客户端，为 nil 时使用一个超时时间为 30 秒的客户端
+	Client *http.Client
+}
+
+// Notify 实现了 Notifier 接口，响应的状态码不是 2xx 时返回错误
+func (w *WebhookNotifier) Notify(n *Notification) error {
+	data, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+
+	client := w.Client
+	if client == nil {
+		client = webhookClient
+	}
+
+	resp, err := client.Post(w.URL, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook %s: HTTP Response Error %d", w.URL, resp.StatusCode)
+	}
+
+	return nil
+}
+
+// SMTPNotifier 把新结果以邮件的形式发送给 To
+type SMTPNotifier struct {
+	Addr string
+	From string
+	To   []string
+
+	// Auth 为 nil 时不进行认证
+	Auth smtp.Auth
+}
+
+// Notify 实现了 Notifier 接口
+func (s *SMTPNotifier) Notify(n *Notification) error {
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", s.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(s.To, ", "))
+	fmt.Fprintf(&msg, "Subject: [%s] %d new results for %q\r\n", n.Search, len(n.Results), n.Query)
+	fmt.Fprintf(&msg, "Date: %s\r\n", n.Time.Format(time.RFC1123Z))
+	fmt.Fprintf(&msg, "Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(formatText(n), "\n", "\r\n"))
+
+	return smtp.SendMail(s.Addr, s.Auth, s.From, s.To, msg.Bytes())
+}
+
+// formatText 把通知格式化成和 search.Display 一致的文本
+func formatText(n *Notification) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "[%s] %d new results for %q at %s\n\n", n.Search, len(n.Results), n.Query, n.Time.Format(time.RFC3339))
+
+	for _, result := range n.Results {
+		fmt.Fprintf(&b, "%s:\n%s\n", result.Field, result.Content)
+		if result.Item != nil && result.Item.Link != "" {
+			fmt.Fprintf(&b, "%s\n", result.Item.Link)
+		}
+		b.WriteString("\n")
+	}
+
+	return b.String()
+}
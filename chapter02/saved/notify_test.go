@@ -0,0 +1,342 @@
+// 这个示例程序使用本地的替身服务测试每一种通知方式
+package saved_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	_ "notes.goinaction/chapter02/matchers"
+	"notes.goinaction/chapter02/saved"
+	"notes.goinaction/chapter02/search"
+)
+
+const checkMark = "\u2713"
+const ballotX = "\u2717"
+
+// notification 是所有测试共用的通知
+var notification = saved.Notification{
+	Search: "morning",
+	Query:  "president",
+	Time:   time.Date(2021, 4, 17, 8, 0, 0, 0, time.UTC),
+	Results: []*search.Result{
+		{
+			Field:   "Title",
+			Content: "The president speaks",
+			Item:    &search.Item{Title: "The president speaks", Link: "http://example.com/1"},
+		},
+	},
+}
+
+// smtpSink 是一个只在内存里接收邮件的 SMTP 服务器，收到的每封邮件会写入 messages 通道
+func smtpSink(t *testing.T) (addr string, messages <-chan string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("\tShould be able to start the SMTP sink.", ballotX, err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	ch := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		r := bufio.NewReader(conn)
+		reply := func(s string) { fmt.Fprintf(conn, "%s\r\n", s) }
+		reply("220 sink ready")
+
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+
+			switch cmd := strings.ToUpper(strings.TrimSpace(line)); {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				reply("250 sink")
+			case strings.HasPrefix(cmd, "DATA"):
+				reply("354 go ahead")
+				var data strings.Builder
+				for {
+					l, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if l == ".\r\n" {
+						break
+					}
+					data.WriteString(l)
+				}
+				ch <- data.String()
+				reply("250 queued")
+			case strings.HasPrefix(cmd, "QUIT"):
+				reply("221 bye")
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+	}()
+
+	return ln.Addr().String(), ch
+}
+
+// TestNotifiers 确认每一种通知方式都能把结果发送出去
+func TestNotifiers(t *testing.T) {
+	t.Log("Given the need to deliver new results.")
+	{
+		t.Log("\tWhen writing to a writer.")
+		{
+			var buf bytes.Buffer
+			n := saved.WriterNotifier{W: &buf}
+			if err := n.Notify(&notification); err != nil {
+				t.Fatal("\t\tShould be able to notify.", ballotX, err)
+			}
+			if strings.Contains(buf.String(), "http://example.com/1") {
+				t.Log("\t\tShould write the result link.", checkMark)
+			} else {
+				t.Error("\t\tShould write the result link.", ballotX, buf.String())
+			}
+		}
+
+		t.Log("\tWhen appending to a file.")
+		{
+			path := filepath.Join(t.TempDir(), "results.jsonl")
+			n := saved.FileNotifier{Path: path}
+			for i := 0; i < 2; i++ {
+				if err := n.Notify(&notification); err != nil {
+					t.Fatal("\t\tShould be able to notify.", ballotX, err)
+				}
+			}
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal("\t\tShould be able to read the file.", ballotX, err)
+			}
+			if lines := strings.Count(string(data), "\n"); lines == 2 {
+				t.Log("\t\tShould append one line per notification.", checkMark)
+			} else {
+				t.Error("\t\tShould append one line per notification.", ballotX, lines)
+			}
+		}
+
+		t.Log("\tWhen posting to a webhook.")
+		{
+			received := make(chan saved.Notification, 1)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var n saved.Notification
+				json.NewDecoder(r.Body).Decode(&n)
+				received <- n
+				w.WriteHeader(http.StatusNoContent)
+			}))
+			defer server.Close()
+
+			n := saved.WebhookNotifier{URL: server.URL}
+			if err := n.Notify(&notification); err != nil {
+				t.Fatal("\t\tShould be able to notify.", ballotX, err)
+			}
+			if got := <-received; got.Search == "morning" && len(got.Results) == 1 {
+				t.Log("\t\tShould post the notification as JSON.", checkMark)
+			} else {
+				t.Error("\t\tShould post the notification as JSON.", ballotX, got)
+			}
+
+			failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusInternalServerError)
+			}))
+			defer failing.Close()
+
+			n = saved.WebhookNotifier{URL: failing.URL}
+			if err := n.Notify(&notification); err != nil {
+				t.Log("\t\tShould report a non-2xx response.", checkMark)
+			} else {
+				t.Error("\t\tShould report a non-2xx response.", ballotX)
+			}
+		}
+
+		t.Log("\tWhen sending an email.")
+		{
+			addr, messages := smtpSink(t)
+			n := saved.SMTPNotifier{Addr: addr, From: "search@example.com", To: []string{"team@example.com"}}
+			if err := n.Notify(&notification); err != nil {
+				t.Fatal("\t\tShould be able to notify.", ballotX, err)
+			}
+
+			msg := <-messages
+			if strings.Contains(msg, "Subject: [morning] 1 new results") && strings.Contains(msg, "The president speaks") {
+				t.Log("\t\tShould deliver the message to the sink.", checkMark)
+			} else {
+				t.Error("\t\tShould deliver the message to the sink.", ballotX, msg)
+			}
+		}
+	}
+}
+
+// TestSchedulerOnlyNew 确认同一个结果只会被发送一次
+func TestSchedulerOnlyNew(t *testing.T) {
+	feed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<rss><channel><item><title>The president speaks</title><link>http://example.com/1</link></item></channel></rss>`)
+	}))
+	defer feed.Close()
+
+	path := filepath.Join(t.TempDir(), "results.jsonl")
+	searches := []*saved.SavedSearch{
+		{
+			Name:         "morning",
+			Query:        "president",
+			Schedule:     "@daily",
+			Destinations: []saved.Destination{{Type: "file", Path: path}},
+		},
+	}
+
+	feeds := search.NewFeedList([]*search.Feed{{Name: "mock", URI: feed.URL, Type: "rss"}})
+	scheduler, err := saved.NewScheduler(feeds, searches)
+	if err != nil {
+		t.Fatal("\tShould be able to create the scheduler.", ballotX, err)
+	}
+
+	t.Log("Given the need to run a saved search twice.")
+	{
+		for i := 0; i < 2; i++ {
+			if err := scheduler.RunAll(); err != nil {
+				t.Fatal("\tShould be able to run the saved search.", ballotX, err)
+			}
+		}
+
+		data, _ := os.ReadFile(path)
+		if lines := strings.Count(string(data), "\n"); lines == 1 {
+			t.Log("\tShould notify only the first time.", checkMark)
+		} else {
+			t.Error("\tShould notify only the first time.", ballotX, lines)
+		}
+	}
+}
+
+// TestSchedulerRetry 确认发送失败的结果下一次执行时会重新发送，而不会重复发送到其他目的地
+func TestSchedulerRetry(t *testing.T) {
+	feed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<rss><channel><item><title>The president speaks</title><link>http://example.com/1</link></item></channel></rss>`)
+	}))
+	defer feed.Close()
+
+	// 第一次请求返回 500，之后的请求都成功
+	var calls int
+	received := make(chan saved.Notification, 2)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls++; calls == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		var n saved.Notification
+		json.NewDecoder(r.Body).Decode(&n)
+		received <- n
+	}))
+	defer webhook.Close()
+
+	path := filepath.Join(t.TempDir(), "results.jsonl")
+	searches := []*saved.SavedSearch{
+		{
+			Name:     "morning",
+			Query:    "president",
+			Schedule: "@daily",
+			Destinations: []saved.Destination{
+				{Type: "webhook", URL: webhook.URL},
+				{Type: "file", Path: path},
+			},
+		},
+	}
+
+	feeds := search.NewFeedList([]*search.Feed{{Name: "mock", URI: feed.URL, Type: "rss"}})
+	scheduler, err := saved.NewScheduler(feeds, searches)
+	if err != nil {
+		t.Fatal("\tShould be able to create the scheduler.", ballotX, err)
+	}
+
+	t.Log("Given the need to retry a destination that failed.")
+	{
+		if err := scheduler.RunAll(); err != nil {
+			t.Log("\tShould report the failed webhook.", checkMark)
+		} else {
+			t.Error("\tShould report the failed webhook.", ballotX)
+		}
+
+		if err := scheduler.RunAll(); err != nil {
+			t.Fatal("\tShould be able to run the saved search again.", ballotX, err)
+		}
+
+		select {
+		case n := <-received:
+			if len(n.Results) == 1 && n.Results[0].Content == "The president speaks" {
+				t.Log("\tShould send the result on the second run.", checkMark)
+			} else {
+				t.Error("\tShould send the result on the second run.", ballotX, n.Results)
+			}
+		default:
+			t.Error("\tShould send the result on the second run.", ballotX)
+		}
+
+		data, _ := os.ReadFile(path)
+		if lines := strings.Count(string(data), "\n"); lines == 1 {
+			t.Log("\tShould not resend to the file that already has the result.", checkMark)
+		} else {
+			t.Error("\tShould not resend to the file that already has the result.", ballotX, lines)
+		}
+	}
+}
+
+// TestLoad 确认 Load 在读取时就拒绝无效的查询和执行计划
+func TestLoad(t *testing.T) {
+	var searches = []struct {
+		name  string
+		query string
+		spec  string
+		ok    bool
+	}{
+		{"valid", "president|senate", "@daily", true},
+		{"empty query", "", "@daily", false},
+		{"invalid query", "(president", "@daily", false},
+		{"invalid schedule", "president", "@never", false},
+	}
+
+	t.Log("Given the need to validate saved searches when loading them.")
+	{
+		for _, s := range searches {
+			t.Logf("\tWhen loading a search with %s", s.name)
+			{
+				path := filepath.Join(t.TempDir(), "searches.json")
+				data, _ := json.Marshal([]*saved.SavedSearch{{
+					Name:         "morning",
+					Query:        s.query,
+					Schedule:     s.spec,
+					Destinations: []saved.Destination{{Type: "stdout"}},
+				}})
+				os.WriteFile(path, data, 0644)
+
+				_, err := saved.Load(path)
+				switch {
+				case s.ok && err == nil:
+					t.Log("\t\tShould load the search.", checkMark)
+				case s.ok:
+					t.Error("\t\tShould load the search.", ballotX, err)
+				case err != nil:
+					t.Log("\t\tShould reject the search.", checkMark, err)
+				default:
+					t.Error("\t\tShould reject the search.", ballotX)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,109 @@
+// Package saved 包按计划定时执行保存下来的搜索，并把新出现的结果发送到指定的目的地
+package saved
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+
+	"notes.goinaction/chapter02/search"
+	"notes.goinaction/cron"
+)
+
+// Destination 描述了搜索结果的一个发送目的地，Type 决定使用哪些字段
+type Destination struct {
+	// Type 可以是 stdout、file、webhook 或 smtp
+	Type string `json:"type"`
+
+	// Path 是 file 类型要追加写入的文件
+	Path string `json:"path,omitempty"`
+
+	// URL 是 webhook 类型要 POST 的地址
+	URL string `json:"url,omitempty"`
+
+	// Addr、From、To 是 smtp 类型的服务器地址、发件人和收件人
+	Addr string   `json:"addr,omitempty"`
+	From string   `json:"from,omitempty"`
+	To   []string `json:"to,omitempty"`
+
+	// Username 和 PasswordEnv 是 smtp 的认证信息，密码从 PasswordEnv 指定的环境变量读取
+	Username    string `json:"username,omitempty"`
+	PasswordEnv string `json:"password_env,omitempty"`
+}
+
+// SavedSearch 是一个保存下来的搜索
+type SavedSearch struct {
+	Name  string `json:"name"`
+	Query string `json:"query"`
+
+	// Feeds 是要搜索的数据源的名字，可以使用通配符，为空时不按名字挑选
+	Feeds []string `json:"feeds,omitempty"`
+
+	// Tags 是要搜索的数据源的标签，为空时不按标签挑选
+	Tags []string `json:"tags,omitempty"`
+
+	// Schedule 是执行计划，格式见 cron.Parse
+	Schedule string `json:"schedule"`
+
+	Destinations []Destination `json:"destinations"`
+}
+
+// Load 读取并反序列化保存的搜索列表，同时检查每个搜索的查询、执行计划和目的地是否有效
+func Load(path string) ([]*SavedSearch, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var searches []*SavedSearch
+	if err := json.NewDecoder(file).Decode(&searches); err != nil {
+		return nil, err
+	}
+
+	for _, s := range searches {
+		if err := s.validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	return searches, nil
+}
+
+// validate 检查搜索的定义是否完整
+func (s *SavedSearch) validate() error {
+	if s.Name == "" {
+		return errors.New("saved search without a name")
+	}
+	if s.Query == "" {
+		return fmt.Errorf("saved search %q: empty query", s.Name)
+	}
+	if _, err := regexp.Compile(s.Query); err != nil {
+		return fmt.Errorf("saved search %q: invalid query: %v", s.Name, err)
+	}
+	if _, err := cron.Parse(s.Schedule); err != nil {
+		return fmt.Errorf("saved search %q: %v", s.Name, err)
+	}
+	if len(s.Destinations) == 0 {
+		return fmt.Errorf("saved search %q: no destinations", s.Name)
+	}
+	for _, d := range s.Destinations {
+		if _, err := NewNotifier(d); err != nil {
+			return fmt.Errorf("saved search %q: %v", s.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// options 返回执行这个搜索时使用的搜索条件
+func (s *SavedSearch) options() *search.Options {
+	return &search.Options{
+		Feeds: &search.FeedFilter{
+			Names: s.Feeds,
+			Tags:  s.Tags,
+		},
+	}
+}
@@ -0,0 +1,216 @@
+package saved
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"notes.goinaction/chapter02/search"
+	"notes.goinaction/cron"
+)
+
+// job 是一个保存的搜索在调度器里的运行状态
+type job struct {
+	search   *SavedSearch
+	schedule cron.Schedule
+	targets  []*target
+
+	// next 是下一次执行的时间
+	next time.Time
+}
+
+/*
+target 是一个目的地和已经成功发送到这里的结果
+
+每个目的地分别记录发送过的结果。发送失败的结果下一次执行时会重新发送，
+而已经成功发送到其他目的地的结果不会重复发送。
+*/
+type target struct {
+	notifier Notifier
+	seen     map[string]bool
+}
+
+// Scheduler 按照每个保存的搜索的执行计划定时执行搜索
+type Scheduler struct {
+	feeds *search.FeedList
+	jobs  []*job
+}
+
+// NewScheduler 为保存的搜索创建调度器，所有搜索共享同一个数据源列表
+func NewScheduler(feeds *search.FeedList, searches []*SavedSearch) (*Scheduler, error) {
+	s := Scheduler{feeds: feeds}
+
+	for _, ss := range searches {
+		if err := ss.validate(); err != nil {
+			return nil, err
+		}
+
+		schedule, _ := cron.Parse(ss.Schedule)
+		j := job{
+			search:   ss,
+			schedule: schedule,
+		}
+		for _, d := range ss.Destinations {
+			n, _ := NewNotifier(d)
+			j.targets = append(j.targets, &target{notifier: n, seen: make(map[string]bool)})
+		}
+
+		s.jobs = append(s.jobs, &j)
+	}
+
+	return &s, nil
+}
+
+// RunAll 立即执行所有搜索一次，返回遇到的第一个错误
+func (s *Scheduler) RunAll() error {
+	return s.runJobs(s.jobs, time.Now())
+}
+
+// Run 按照执行计划运行所有搜索，直到 ctx 被取消
+func (s *Scheduler) Run(ctx context.Context) error {
+	now := time.Now()
+	for _, j := range s.jobs {
+		j.next = j.schedule.Next(now)
+	}
+
+	for {
+		next, ok := s.nextTime()
+		if !ok {
+			return errors.New("no saved search will ever run again")
+		}
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+
+		case now := <-timer.C:
+			// 找出所有已经到期的搜索，计算它们的下一次执行时间
+			var due []*job
+			for _, j := range s.jobs {
+				if !j.next.After(now) {
+					due = append(due, j)
+					j.next = j.schedule.Next(now)
+				}
+			}
+
+			// 某个搜索失败不影响其他搜索，也不影响下一次执行
+			if err := s.runJobs(due, now); err != nil {
+				log.Println(err)
+			}
+		}
+	}
+}
+
+// nextTime 返回所有搜索里最早的下一次执行时间
+func (s *Scheduler) nextTime() (time.Time, bool) {
+	var next time.Time
+	for _, j := range s.jobs {
+		if j.next.IsZero() {
+			continue
+		}
+		if next.IsZero() || j.next.Before(next) {
+			next = j.next
+		}
+	}
+
+	return next, !next.IsZero()
+}
+
+// runJobs 并发地执行一组搜索，等待它们全部完成
+func (s *Scheduler) runJobs(jobs []*job, now time.Time) error {
+	errs := make([]error, len(jobs))
+
+	var wg sync.WaitGroup
+	wg.Add(len(jobs))
+	for i, j := range jobs {
+		go func(i int, j *job) {
+			errs[i] = s.runJob(j, now)
+			wg.Done()
+		}(i, j)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// runJob 执行一个搜索，把每个目的地还没有收到的结果发送过去
+func (s *Scheduler) runJob(j *job, now time.Time) error {
+	results := search.Find(s.feeds.All(), j.search.Query, j.search.options())
+
+	// 尝试发送到所有目的地，返回遇到的所有错误
+	var failed []string
+	for _, t := range j.targets {
+		if err := s.notify(j, t, results, now); err != nil {
+			failed = append(failed, err.Error())
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("saved search %q: %s", j.search.Name, strings.Join(failed, "; "))
+	}
+
+	return nil
+}
+
+// notify 把目的地 t 没有收到过的结果发送过去，发送成功之后才把这些结果记为已发送
+func (s *Scheduler) notify(j *job, t *target, results []*search.Result, now time.Time) error {
+	var (
+		keys  []string
+		fresh []*search.Result
+	)
+	for _, result := range results {
+		key := resultKey(result)
+		if !t.seen[key] {
+			keys = append(keys, key)
+
+			// 通知会发送到外部的地址，结果里的数据源只保留可以公开的字段
+			fresh = append(fresh, result.Public())
+		}
+	}
+
+	if len(fresh) == 0 {
+		return nil
+	}
+
+	n := Notification{
+		Search:  j.search.Name,
+		Query:   j.search.Query,
+		Time:    now,
+		Results: fresh,
+	}
+	if err := t.notifier.Notify(&n); err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		t.seen[key] = true
+	}
+	return nil
+}
+
+// resultKey 返回用来判断结果是否已经发送过的值
+func resultKey(r *search.Result) string {
+	var site, id string
+	if r.Feed != nil {
+		site = r.Feed.Name
+	}
+	if r.Item != nil {
+		id = r.Item.ID()
+	} else {
+		id = r.Content
+	}
+
+	return site + "\x00" + r.Field + "\x00" + id
+}
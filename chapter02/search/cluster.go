@@ -0,0 +1,154 @@
+package search
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/bits"
+	"strings"
+)
+
+// DefaultClusterDistance 是两个结果被认为近似重复时，SimHash 指纹之间允许的最大汉明距离
+const DefaultClusterDistance = 12
+
+// shingleSize 是计算指纹时每个片段包含的词数
+const shingleSize = 2
+
+// Cluster 是一组近似重复的结果，例如多个数据源转载的同一篇通讯社稿件
+type Cluster struct {
+	// Representative 是代表这一组的结果，优先选择标题匹配的结果
+	Representative *Result `json:"representative"`
+
+	// Results 是这一组里的全部结果，包括 Representative
+	Results []*Result `json:"results"`
+}
+
+// Sources 返回这一组结果来自的数据源的名字，保持第一次出现的顺序
+func (c *Cluster) Sources() []string {
+	var sources []string
+	seen := make(map[string]bool)
+
+	for _, r := range c.Results {
+		if r.Feed == nil || seen[r.Feed.Name] {
+			continue
+		}
+		seen[r.Feed.Name] = true
+		sources = append(sources, r.Feed.Name)
+	}
+
+	return sources
+}
+
+/*
+ClusterResults 把近似重复的结果合并成组，返回的组保持每组第一个结果出现的顺序
+
+每个结果使用所在条目的标题和描述计算 SimHash 指纹：先把文本拆成词，相邻的 shingleSize 个词组成
+一个片段，每个片段的哈希值在 64 个比特位上投票，得票为正的位置为 1。内容相近的文本大部分片段相同，
+所以指纹之间只有很少的比特位不同。指纹的汉明距离不超过 maxDistance 的两个结果会被放进同一组，
+这个关系是可以传递的。
+*/
+func ClusterResults(results []*Result, maxDistance int) []*Cluster {
+	fingerprints := make([]uint64, len(results))
+	for i, r := range results {
+		fingerprints[i] = SimHash(clusterText(r))
+	}
+
+	// 使用并查集合并距离足够近的结果
+	parent := make([]int, len(results))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(i int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+
+	for i := range results {
+		for j := i + 1; j < len(results); j++ {
+			if bits.OnesCount64(fingerprints[i]^fingerprints[j]) <= maxDistance {
+				if ri, rj := find(i), find(j); ri != rj {
+					parent[rj] = ri
+				}
+			}
+		}
+	}
+
+	var clusters []*Cluster
+	index := make(map[int]*Cluster)
+	for i, r := range results {
+		root := find(i)
+		c, exists := index[root]
+		if !exists {
+			c = &Cluster{}
+			index[root] = c
+			clusters = append(clusters, c)
+		}
+		c.Results = append(c.Results, r)
+
+		if c.Representative == nil || (c.Representative.Field != "Title" && r.Field == "Title") {
+			c.Representative = r
+		}
+	}
+
+	return clusters
+}
+
+// SimHash 计算文本的 64 位 SimHash 指纹
+func SimHash(text string) uint64 {
+	tokens := Tokenize(text)
+
+	// 文本太短时，整段文本作为一个片段
+	var shingles []string
+	if len(tokens) < shingleSize {
+		shingles = []string{strings.Join(tokens, " ")}
+	} else {
+		for i := 0; i+shingleSize <= len(tokens); i++ {
+			shingles = append(shingles, strings.Join(tokens[i:i+shingleSize], " "))
+		}
+	}
+
+	var votes [64]int
+	for _, shingle := range shingles {
+		h := fnv.New64a()
+		h.Write([]byte(shingle))
+		sum := h.Sum64()
+
+		for bit := 0; bit < 64; bit++ {
+			if sum&(1<<uint(bit)) != 0 {
+				votes[bit]++
+			} else {
+				votes[bit]--
+			}
+		}
+	}
+
+	var fingerprint uint64
+	for bit, v := range votes {
+		if v > 0 {
+			fingerprint |= 1 << uint(bit)
+		}
+	}
+
+	return fingerprint
+}
+
+// clusterText 返回计算指纹使用的文本，优先使用条目的标题和描述，没有条目时使用匹配的内容
+func clusterText(r *Result) string {
+	if r.Item != nil {
+		return r.Item.Title + " " + r.Item.Description
+	}
+	return r.Content
+}
+
+// DisplayClusters 在终端窗口输出每组的代表结果，以及转载这个结果的数据源
+func DisplayClusters(clusters []*Cluster) {
+	for _, c := range clusters {
+		fmt.Printf("%s:\n%s\n", c.Representative.Field, c.Representative.Content)
+		if sources := c.Sources(); len(sources) > 0 {
+			fmt.Printf("Sources: %s\n", strings.Join(sources, ", "))
+		}
+		fmt.Println()
+	}
+}
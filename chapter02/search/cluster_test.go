@@ -0,0 +1,90 @@
+// 这个示例程序测试 SimHash 指纹和近似重复结果的合并
+package search_test
+
+import (
+	"math/bits"
+	"testing"
+
+	"notes.goinaction/chapter02/search"
+)
+
+// 同一篇通讯社稿件被两个数据源转载，第二个数据源改动了几个词，另一篇是无关的文章
+const (
+	wire      = "Central bank raises interest rates by a quarter point to fight inflation, the largest increase since 2008, officials said on Wednesday after a two day meeting"
+	reprint   = "Central bank raises interest rates by a quarter point to fight inflation, the largest increase since 2008, officials said Wednesday after a two-day policy meeting"
+	unrelated = "Local football club wins the championship after a dramatic penalty shootout in front of a record crowd at the stadium"
+)
+
+// TestSimHash 确认相同的文本得到相同的指纹，近似的文本指纹接近，无关的文本指纹相差很远
+func TestSimHash(t *testing.T) {
+	t.Log("Given the need to fingerprint similar texts.")
+	{
+		if search.SimHash(wire) == search.SimHash(wire) {
+			t.Log("\tShould give the same text the same fingerprint.", checkMark)
+		} else {
+			t.Error("\tShould give the same text the same fingerprint.", ballotX)
+		}
+
+		if d := bits.OnesCount64(search.SimHash(wire) ^ search.SimHash(reprint)); d <= search.DefaultClusterDistance {
+			t.Logf("\tShould keep near duplicates close. %v distance %d", checkMark, d)
+		} else {
+			t.Errorf("\tShould keep near duplicates close. %v distance %d", ballotX, d)
+		}
+
+		if d := bits.OnesCount64(search.SimHash(wire) ^ search.SimHash(unrelated)); d > search.DefaultClusterDistance {
+			t.Logf("\tShould keep unrelated texts apart. %v distance %d", checkMark, d)
+		} else {
+			t.Errorf("\tShould keep unrelated texts apart. %v distance %d", ballotX, d)
+		}
+	}
+}
+
+// TestClusterResults 确认近似重复的结果被合并，组的顺序和代表结果的选择符合预期
+func TestClusterResults(t *testing.T) {
+	reuters := &search.Feed{Name: "reuters"}
+	cnn := &search.Feed{Name: "cnn"}
+	espn := &search.Feed{Name: "espn"}
+
+	results := []*search.Result{
+		{Field: "Description", Content: wire, Feed: reuters, Item: &search.Item{Description: wire}},
+		{Field: "Description", Content: unrelated, Feed: espn, Item: &search.Item{Description: unrelated}},
+		{Field: "Title", Content: reprint, Feed: cnn, Item: &search.Item{Title: reprint}},
+		{Field: "Description", Content: reprint, Feed: cnn, Item: &search.Item{Description: reprint}},
+	}
+
+	t.Log("Given the need to cluster near duplicate results.")
+	{
+		clusters := search.ClusterResults(results, search.DefaultClusterDistance)
+
+		if len(clusters) == 2 {
+			t.Log("\tShould merge the reprints into one cluster.", checkMark)
+		} else {
+			t.Fatal("\tShould merge the reprints into one cluster.", ballotX, len(clusters))
+		}
+
+		news := clusters[0]
+		if len(news.Results) == 3 && clusters[1].Results[0].Feed == espn {
+			t.Log("\tShould keep the order of the first result of each cluster.", checkMark)
+		} else {
+			t.Error("\tShould keep the order of the first result of each cluster.", ballotX, len(news.Results))
+		}
+
+		if news.Representative == results[2] {
+			t.Log("\tShould prefer a title match as the representative.", checkMark)
+		} else {
+			t.Error("\tShould prefer a title match as the representative.", ballotX, news.Representative.Field)
+		}
+
+		if sources := news.Sources(); equal(sources, []string{"reuters", "cnn"}) {
+			t.Log("\tShould list each source once.", checkMark)
+		} else {
+			t.Error("\tShould list each source once.", ballotX, sources)
+		}
+
+		if clusters := search.ClusterResults(results, 0); len(clusters) == 3 {
+			t.Log("\tShould only merge identical texts with distance 0.", checkMark)
+		} else {
+			t.Error("\tShould only merge identical texts with distance 0.", ballotX, len(clusters))
+		}
+	}
+}
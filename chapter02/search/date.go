@@ -0,0 +1,92 @@
+package search
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+/*
+dateLayouts 是 RSS 和 Atom 数据源里常见的日期格式，按照出现的频率排列
+
+RSS 规定使用 RFC822 格式，但实际的数据源里经常会出现省略星期、两位数年份、省略秒数、
+单数字日期等各种变体，Atom 则使用 RFC3339 格式。
+*/
+var dateLayouts = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"Mon, 2 Jan 2006 15:04 -0700",
+	"Mon, 2 Jan 2006 15:04 MST",
+	"Mon, 2 Jan 06 15:04:05 -0700",
+	"Mon, 2 Jan 06 15:04:05 MST",
+	"Mon, 2 Jan 06 15:04 -0700",
+	"2 Jan 2006 15:04:05 -0700",
+	"2 Jan 2006 15:04:05 MST",
+	"2 Jan 2006 15:04 -0700",
+	"2 Jan 06 15:04:05 -0700",
+	time.RFC822Z,
+	time.RFC822,
+	time.RFC850,
+	time.RFC3339Nano,
+	time.RFC3339,
+	"2006-01-02T15:04:05Z0700",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+	time.ANSIC,
+	time.UnixDate,
+}
+
+/*
+zoneOffsets 是 RFC822 里定义的时区缩写
+
+time.Parse 遇到本地时区不认识的缩写时，会把偏移当作 0，所以在解析之前先把这些缩写替换成数字偏移。
+*/
+var zoneOffsets = map[string]string{
+	"UT":  "+0000",
+	"UTC": "+0000",
+	"GMT": "+0000",
+	"Z":   "+0000",
+	"EST": "-0500",
+	"EDT": "-0400",
+	"CST": "-0600",
+	"CDT": "-0500",
+	"MST": "-0700",
+	"MDT": "-0600",
+	"PST": "-0800",
+	"PDT": "-0700",
+}
+
+// ParseDate 尽可能宽容地解析 RSS 和 Atom 数据源里的日期
+func ParseDate(s string) (time.Time, error) {
+	value := normalizeDate(s)
+	if value == "" {
+		return time.Time{}, fmt.Errorf("invalid date %q: empty", s)
+	}
+
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid date %q: unknown format", s)
+}
+
+// normalizeDate 合并多余的空白，并把末尾的时区缩写替换成数字偏移
+func normalizeDate(s string) string {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return ""
+	}
+
+	last := len(fields) - 1
+	if offset, exists := zoneOffsets[strings.ToUpper(fields[last])]; exists && last > 0 {
+		fields[last] = offset
+	}
+
+	return strings.Join(fields, " ")
+}
@@ -0,0 +1,61 @@
+// 这个示例程序使用表组测试 RSS 和 Atom 日期的解析
+package search_test
+
+import (
+	"testing"
+	"time"
+
+	"notes.goinaction/chapter02/search"
+)
+
+// TestParseDate 确认常见的 RFC822 变体、RFC3339 以及时区缩写都能被正确解析
+func TestParseDate(t *testing.T) {
+	est := time.FixedZone("", -5*60*60)
+	pdt := time.FixedZone("", -7*60*60)
+
+	var dates = []struct {
+		value string
+		want  time.Time
+	}{
+		{"Sun, 15 Mar 2015 15:04:05 +0000", time.Date(2015, 3, 15, 15, 4, 5, 0, time.UTC)},
+		{"Sun, 15 Mar 2015 15:04:05 GMT", time.Date(2015, 3, 15, 15, 4, 5, 0, time.UTC)},
+		{"Sun, 15 Mar 2015 15:04:05 EST", time.Date(2015, 3, 15, 15, 4, 5, 0, est)},
+		{"Sun, 15 Mar 2015 15:04:05 pdt", time.Date(2015, 3, 15, 15, 4, 5, 0, pdt)},
+		{"Sun, 5 Mar 2015 15:04:05 -0500", time.Date(2015, 3, 5, 15, 4, 5, 0, est)},
+		{"Sun, 15 Mar 2015 15:04 +0000", time.Date(2015, 3, 15, 15, 4, 0, 0, time.UTC)},
+		{"Sun, 15 Mar 15 15:04:05 +0000", time.Date(2015, 3, 15, 15, 4, 5, 0, time.UTC)},
+		{"15 Mar 2015 15:04:05 UT", time.Date(2015, 3, 15, 15, 4, 5, 0, time.UTC)},
+		{"  Sun,  15 Mar 2015\t15:04:05   +0000 ", time.Date(2015, 3, 15, 15, 4, 5, 0, time.UTC)},
+		{"2015-03-15T15:04:05Z", time.Date(2015, 3, 15, 15, 4, 5, 0, time.UTC)},
+		{"2015-03-15T15:04:05.123-05:00", time.Date(2015, 3, 15, 15, 4, 5, 123000000, est)},
+		{"2015-03-15", time.Date(2015, 3, 15, 0, 0, 0, 0, time.UTC)},
+	}
+
+	t.Log("Given the need to parse feed dates.")
+	{
+		for _, d := range dates {
+			t.Logf("\tWhen parsing %q", d.value)
+			{
+				got, err := search.ParseDate(d.value)
+				if err != nil {
+					t.Error("\t\tShould parse the date.", ballotX, err)
+					continue
+				}
+
+				if got.Equal(d.want) {
+					t.Log("\t\tShould parse the date.", checkMark)
+				} else {
+					t.Error("\t\tShould parse the date.", ballotX, got)
+				}
+			}
+		}
+
+		for _, value := range []string{"", "   ", "yesterday", "32 Mar 2015 15:04:05 +0000"} {
+			if _, err := search.ParseDate(value); err != nil {
+				t.Logf("\tShould reject %q %v", value, checkMark)
+			} else {
+				t.Errorf("\tShould reject %q %v", value, ballotX)
+			}
+		}
+	}
+}
@@ -20,12 +20,53 @@ type Feed struct {
 	Name string `json:"site"`
 	URI  string `json:"link"`
 	Type string `json:"type"`
+
+	// Tags 是数据源的分类标签，例如 tech、zh，搜索时可以按标签挑选数据源
+	Tags []string `json:"tags,omitempty"`
+
+	// 以下是可选的请求设置，只有需要认证或特殊网络环境的数据源才需要配置，详见 Fetcher
+
+	// Headers 是请求时附加的头部，例如 User-Agent
+	Headers map[string]string `json:"headers,omitempty"`
+
+	// Auth 是请求时使用的认证方式
+	Auth *Auth `json:"auth,omitempty"`
+
+	// Proxy 是请求时使用的代理地址，为空时使用 HTTP_PROXY 等环境变量
+	Proxy string `json:"proxy,omitempty"`
+
+	// TLS 是请求 https 地址时使用的 TLS 设置
+	TLS *TLSConfig `json:"tls,omitempty"`
+}
+
+// HasRequestSettings 报告数据源是否设置了头部、认证、代理或 TLS
+func (f *Feed) HasRequestSettings() bool {
+	return len(f.Headers) > 0 || f.Auth != nil || f.Proxy != "" || f.TLS != nil
+}
+
+/*
+Public 返回只包含名字、地址、类型和标签的副本，用于对外输出
+
+请求设置里有令牌、凭据的名字和服务器上的文件路径，不能出现在接口的响应和发出的通知里。
+*/
+func (f *Feed) Public() *Feed {
+	return &Feed{
+		Name: f.Name,
+		URI:  f.URI,
+		Type: f.Type,
+		Tags: f.Tags,
+	}
 }
 
 // RetrieveFeeds 读取并反序列化源数据文件
 func RetrieveFeeds() ([]*Feed, error) {
+	return readFeeds(dataFile)
+}
+
+// readFeeds 读取并反序列化指定的数据源文件
+func readFeeds(path string) ([]*Feed, error) {
 	// 打开文件
-	file, err := os.Open(dataFile)
+	file, err := os.Open(path)
 	if err != nil {
 		return nil, err
 	}
@@ -45,3 +86,18 @@ func RetrieveFeeds() ([]*Feed, error) {
 	// 这个函数不需要检查错误，调用者会做这件事
 	return feeds, err
 }
+
+// writeFeeds 将数据源列表序列化后写入文件。先写入临时文件再重命名，避免写到一半时留下损坏的文件
+func writeFeeds(path string, feeds []*Feed) error {
+	data, err := json.MarshalIndent(feeds, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,139 @@
+package search
+
+import (
+	"path"
+	"sort"
+)
+
+// untagged 是没有标签的数据源在分组时使用的组名
+const untagged = "(untagged)"
+
+/*
+FeedFilter 决定哪些数据源参与搜索，零值表示使用全部数据源
+
+同一类条件之间是"或"的关系，不同类条件之间是"与"的关系。例如 Tags 为 [tech zh]、Types 为 [rss]
+表示挑选带有 tech 或 zh 标签、并且类型是 rss 的数据源。任何一个排除条件满足时，数据源都会被排除。
+*/
+type FeedFilter struct {
+	Tags  []string
+	Types []string
+
+	// Names 是数据源名字的通配符，语法与 path.Match 相同，例如 "cnn*"
+	Names []string
+
+	ExcludeTags  []string
+	ExcludeTypes []string
+	ExcludeNames []string
+}
+
+// HasTag 报告数据源是否带有指定的标签
+func (f *Feed) HasTag(tag string) bool {
+	for _, t := range f.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// Match 报告数据源是否满足过滤条件
+func (ff *FeedFilter) Match(feed *Feed) bool {
+	if ff == nil {
+		return true
+	}
+
+	if anyTag(feed, ff.ExcludeTags) || contains(ff.ExcludeTypes, feed.Type) || anyName(feed, ff.ExcludeNames) {
+		return false
+	}
+
+	if len(ff.Tags) > 0 && !anyTag(feed, ff.Tags) {
+		return false
+	}
+	if len(ff.Types) > 0 && !contains(ff.Types, feed.Type) {
+		return false
+	}
+	if len(ff.Names) > 0 && !anyName(feed, ff.Names) {
+		return false
+	}
+
+	return true
+}
+
+// Apply 返回满足过滤条件的数据源
+func (ff *FeedFilter) Apply(feeds []*Feed) []*Feed {
+	if ff == nil {
+		return feeds
+	}
+
+	var matched []*Feed
+	for _, feed := range feeds {
+		if ff.Match(feed) {
+			matched = append(matched, feed)
+		}
+	}
+
+	return matched
+}
+
+// GroupByTag 按照标签对数据源分组，带有多个标签的数据源会出现在多个组里，没有标签的数据源单独成组
+func GroupByTag(feeds []*Feed) map[string][]*Feed {
+	groups := make(map[string][]*Feed)
+	for _, feed := range feeds {
+		if len(feed.Tags) == 0 {
+			groups[untagged] = append(groups[untagged], feed)
+			continue
+		}
+		for _, tag := range feed.Tags {
+			groups[tag] = append(groups[tag], feed)
+		}
+	}
+
+	return groups
+}
+
+// Tags 返回排好序的组名，没有标签的组排在最后
+func Tags(groups map[string][]*Feed) []string {
+	tags := make([]string, 0, len(groups))
+	for tag := range groups {
+		if tag != untagged {
+			tags = append(tags, tag)
+		}
+	}
+	sort.Strings(tags)
+
+	if _, exists := groups[untagged]; exists {
+		tags = append(tags, untagged)
+	}
+
+	return tags
+}
+
+// anyTag 报告数据源是否带有 tags 中的任意一个标签
+func anyTag(feed *Feed, tags []string) bool {
+	for _, tag := range tags {
+		if feed.HasTag(tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// anyName 报告数据源的名字是否匹配 patterns 中的任意一个通配符
+func anyName(feed *Feed, patterns []string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, feed.Name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// contains 报告 values 里是否有 value
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
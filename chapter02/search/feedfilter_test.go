@@ -0,0 +1,93 @@
+// 这个示例程序使用表组测试数据源的过滤条件
+package search_test
+
+import (
+	"testing"
+
+	"notes.goinaction/chapter02/search"
+)
+
+// TestFeedFilter 确认同一类条件之间是"或"、不同类条件之间是"与"，排除条件优先
+func TestFeedFilter(t *testing.T) {
+	feeds := []*search.Feed{
+		{Name: "cnn-world", Type: "rss", Tags: []string{"news", "en"}},
+		{Name: "cnn-tech", Type: "rss", Tags: []string{"tech", "en"}},
+		{Name: "ruanyifeng", Type: "atom", Tags: []string{"tech", "zh"}},
+		{Name: "untagged", Type: "rss"},
+	}
+
+	var filters = []struct {
+		name   string
+		filter *search.FeedFilter
+		want   []string
+	}{
+		{"nil filter", nil, []string{"cnn-world", "cnn-tech", "ruanyifeng", "untagged"}},
+		{"zero filter", &search.FeedFilter{}, []string{"cnn-world", "cnn-tech", "ruanyifeng", "untagged"}},
+		{"any of tags", &search.FeedFilter{Tags: []string{"news", "zh"}}, []string{"cnn-world", "ruanyifeng"}},
+		{"tag and type", &search.FeedFilter{Tags: []string{"tech"}, Types: []string{"rss"}}, []string{"cnn-tech"}},
+		{"name pattern", &search.FeedFilter{Names: []string{"cnn*"}}, []string{"cnn-world", "cnn-tech"}},
+		{"exclude tag", &search.FeedFilter{ExcludeTags: []string{"en"}}, []string{"ruanyifeng", "untagged"}},
+		{"exclude wins", &search.FeedFilter{Tags: []string{"tech"}, ExcludeNames: []string{"*-tech"}}, []string{"ruanyifeng"}},
+		{"exclude type", &search.FeedFilter{ExcludeTypes: []string{"rss"}}, []string{"ruanyifeng"}},
+		{"no match", &search.FeedFilter{Types: []string{"json"}}, nil},
+	}
+
+	t.Log("Given the need to choose feeds with filters.")
+	{
+		for _, f := range filters {
+			t.Logf("\tWhen using %s", f.name)
+			{
+				var got []string
+				for _, feed := range f.filter.Apply(feeds) {
+					got = append(got, feed.Name)
+				}
+
+				if equal(got, f.want) {
+					t.Logf("\t\tShould choose %v %v", f.want, checkMark)
+				} else {
+					t.Errorf("\t\tShould choose %v %v %v", f.want, ballotX, got)
+				}
+			}
+		}
+	}
+}
+
+// TestGroupByTag 确认带有多个标签的数据源出现在多个组里，没有标签的组排在最后
+func TestGroupByTag(t *testing.T) {
+	feeds := []*search.Feed{
+		{Name: "a", Tags: []string{"tech", "en"}},
+		{Name: "b"},
+		{Name: "c", Tags: []string{"en"}},
+	}
+
+	t.Log("Given the need to group feeds by tag.")
+	{
+		groups := search.GroupByTag(feeds)
+		tags := search.Tags(groups)
+
+		if equal(tags, []string{"en", "tech", "(untagged)"}) {
+			t.Log("\tShould sort the tags with untagged last.", checkMark)
+		} else {
+			t.Error("\tShould sort the tags with untagged last.", ballotX, tags)
+		}
+
+		if len(groups["en"]) == 2 && len(groups["tech"]) == 1 && len(groups["(untagged)"]) == 1 {
+			t.Log("\tShould put each feed in every group of its tags.", checkMark)
+		} else {
+			t.Error("\tShould put each feed in every group of its tags.", ballotX, groups)
+		}
+	}
+}
+
+// equal 报告两个字符串切片是否相同
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,137 @@
+package search
+
+import (
+	"errors"
+	"sync"
+)
+
+var (
+	// ErrFeedExists 会在添加一个同名的数据源时返回
+	ErrFeedExists = errors.New("feed already exists")
+
+	// ErrFeedNotFound 会在修改或删除一个不存在的数据源时返回
+	ErrFeedNotFound = errors.New("feed not found")
+)
+
+// FeedList 是一个可以安全地在多个 goroutine 间共享的数据源列表，数据源以名字区分
+type FeedList struct {
+	m     sync.RWMutex
+	path  string
+	feeds []*Feed
+}
+
+// LoadFeedList 从文件里读取数据源列表，之后的修改都会保存回这个文件
+func LoadFeedList(path string) (*FeedList, error) {
+	feeds, err := readFeeds(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FeedList{path: path, feeds: feeds}, nil
+}
+
+// NewFeedList 返回一个只保存在内存里的数据源列表
+func NewFeedList(feeds []*Feed) *FeedList {
+	return &FeedList{feeds: feeds}
+}
+
+// All 返回全部数据源的副本，调用者可以随意修改
+func (l *FeedList) All() []*Feed {
+	l.m.RLock()
+	defer l.m.RUnlock()
+
+	feeds := make([]*Feed, len(l.feeds))
+	for i, feed := range l.feeds {
+		f := *feed
+		feeds[i] = &f
+	}
+
+	return feeds
+}
+
+// Get 返回指定名字的数据源的副本
+func (l *FeedList) Get(name string) (*Feed, error) {
+	l.m.RLock()
+	defer l.m.RUnlock()
+
+	i := l.index(name)
+	if i < 0 {
+		return nil, ErrFeedNotFound
+	}
+
+	f := *l.feeds[i]
+	return &f, nil
+}
+
+// Add 添加一个数据源
+func (l *FeedList) Add(feed *Feed) error {
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	if l.index(feed.Name) >= 0 {
+		return ErrFeedExists
+	}
+
+	f := *feed
+	return l.save(append(l.feeds, &f))
+}
+
+// Update 使用 feed 替换指定名字的数据源，feed 可以使用新的名字
+func (l *FeedList) Update(name string, feed *Feed) error {
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	i := l.index(name)
+	if i < 0 {
+		return ErrFeedNotFound
+	}
+	if feed.Name != name && l.index(feed.Name) >= 0 {
+		return ErrFeedExists
+	}
+
+	feeds := make([]*Feed, len(l.feeds))
+	copy(feeds, l.feeds)
+	f := *feed
+	feeds[i] = &f
+
+	return l.save(feeds)
+}
+
+// Delete 删除指定名字的数据源
+func (l *FeedList) Delete(name string) error {
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	i := l.index(name)
+	if i < 0 {
+		return ErrFeedNotFound
+	}
+
+	feeds := make([]*Feed, 0, len(l.feeds)-1)
+	feeds = append(feeds, l.feeds[:i]...)
+	feeds = append(feeds, l.feeds[i+1:]...)
+
+	return l.save(feeds)
+}
+
+// index 返回指定名字的数据源的下标，不存在时返回 -1。调用者必须持有锁
+func (l *FeedList) index(name string) int {
+	for i, feed := range l.feeds {
+		if feed.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// save 将修改后的列表写入文件，写入成功后才替换内存里的列表。调用者必须持有写锁
+func (l *FeedList) save(feeds []*Feed) error {
+	if l.path != "" {
+		if err := writeFeeds(l.path, feeds); err != nil {
+			return err
+		}
+	}
+
+	l.feeds = feeds
+	return nil
+}
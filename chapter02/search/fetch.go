@@ -0,0 +1,330 @@
+package search
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+)
+
+// Auth 描述了请求数据源时使用的认证方式
+type Auth struct {
+	// Type 可以是 basic 或 bearer
+	Type string `json:"type"`
+
+	// Credential 是凭据文件里的条目名，从凭据文件读取用户名、密码或令牌
+	Credential string `json:"credential,omitempty"`
+
+	// Username 是 basic 认证的用户名，可以直接写在数据源列表里
+	Username string `json:"username,omitempty"`
+
+	// PasswordEnv 和 TokenEnv 是保存密码和令牌的环境变量，设置后会覆盖凭据文件里的值
+	PasswordEnv string `json:"password_env,omitempty"`
+	TokenEnv    string `json:"token_env,omitempty"`
+}
+
+// TLSConfig 描述了请求数据源时使用的 TLS 设置
+type TLSConfig struct {
+	// CAFile 是额外信任的 CA 证书，用于内部签发证书的数据源
+	CAFile string `json:"ca_file,omitempty"`
+
+	// CertFile 和 KeyFile 是客户端证书
+	CertFile string `json:"cert_file,omitempty"`
+	KeyFile  string `json:"key_file,omitempty"`
+
+	// ServerName 用来校验服务端证书的主机名
+	ServerName string `json:"server_name,omitempty"`
+
+	// InsecureSkipVerify 为 true 时不校验服务端证书，只应该在测试时使用
+	InsecureSkipVerify bool `json:"insecure_skip_verify,omitempty"`
+}
+
+// Credentials 是凭据文件里的一个条目，凭据文件是条目名到 Credentials 的 JSON 对象
+type Credentials struct {
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	Token    string `json:"token,omitempty"`
+}
+
+/*
+Fetcher 按照数据源的设置发送请求，所有匹配器共享同一个 Fetcher
+
+没有设置代理和 TLS 的数据源共用 Client，设置了代理或 TLS 的数据源会按照设置创建各自的客户端，
+相同设置的数据源共用同一个客户端，这样连接可以被复用。
+*/
+type Fetcher struct {
+	// Client 是默认使用的客户端
+	Client *http.Client
+
+	// UserAgent 是默认的 User-Agent，数据源的 Headers 里可以覆盖它
+	UserAgent string
+
+	m           sync.Mutex
+	credentials map[string]Credentials
+	clients     map[string]*http.Client
+}
+
+// DefaultFetcher 是匹配器默认使用的 Fetcher
+var DefaultFetcher = NewFetcher(30 * time.Second)
+
+// NewFetcher 返回一个请求超时时间为 timeout 的 Fetcher
+func NewFetcher(timeout time.Duration) *Fetcher {
+	return &Fetcher{
+		Client:    &http.Client{Timeout: timeout},
+		UserAgent: "notes.goinaction-search/1.0",
+	}
+}
+
+// Get 使用 DefaultFetcher 请求数据源
+func Get(feed *Feed) (*http.Response, error) {
+	return DefaultFetcher.Get(feed)
+}
+
+// Retrieve 使用 DefaultFetcher 请求数据源，并用数据源类型对应的匹配器解码出全部条目
+func Retrieve(feed *Feed) ([]*Item, error) {
+	decoder, ok := Lookup(feed.Type).(Decoder)
+	if !ok {
+		return nil, fmt.Errorf("no decoder for feed type %q", feed.Type)
+	}
+
+	resp, err := Get(feed)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP Response Error %d", resp.StatusCode)
+	}
+
+	return decoder.Decode(resp.Body)
+}
+
+// LoadCredentials 读取凭据文件，之后带有 Auth.Credential 的数据源会使用其中的凭据
+func (f *Fetcher) LoadCredentials(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var credentials map[string]Credentials
+	if err := json.NewDecoder(file).Decode(&credentials); err != nil {
+		return fmt.Errorf("credentials %s: %v", path, err)
+	}
+
+	f.m.Lock()
+	f.credentials = credentials
+	f.m.Unlock()
+
+	return nil
+}
+
+/*
+CredentialsFlag 在 fs 里注册 -credentials 命令行参数，返回在解析参数之后调用的加载函数
+
+没有指定凭据文件时加载函数什么也不做。命令行程序通常这样使用：
+
+	var loadCredentials = search.DefaultFetcher.CredentialsFlag(flag.CommandLine)
+
+	flag.Parse()
+	if err := loadCredentials(); err != nil {
+		log.Fatalln(err)
+	}
+*/
+func (f *Fetcher) CredentialsFlag(fs *flag.FlagSet) func() error {
+	path := fs.String("credentials", "", "凭据文件，需要认证的数据源从这里读取用户名、密码或令牌")
+
+	return func() error {
+		if *path == "" {
+			return nil
+		}
+		return f.LoadCredentials(*path)
+	}
+}
+
+// Get 按照数据源的设置发送 GET 请求
+func (f *Fetcher) Get(feed *Feed) (*http.Response, error) {
+	req, err := f.NewRequest(feed)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := f.ClientFor(feed)
+	if err != nil {
+		return nil, err
+	}
+
+	return client.Do(req)
+}
+
+// NewRequest 创建请求数据源的 GET 请求，并设置头部和认证信息
+func (f *Fetcher) NewRequest(feed *Feed) (*http.Request, error) {
+	req, err := http.NewRequest("GET", feed.URI, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if f.UserAgent != "" {
+		req.Header.Set("User-Agent", f.UserAgent)
+	}
+	for key, value := range feed.Headers {
+		req.Header.Set(key, value)
+	}
+
+	if feed.Auth != nil {
+		if err := f.authorize(req, feed.Auth); err != nil {
+			return nil, fmt.Errorf("feed %s: %v", feed.Name, err)
+		}
+	}
+
+	return req, nil
+}
+
+// authorize 按照认证方式给请求加上认证信息
+func (f *Fetcher) authorize(req *http.Request, auth *Auth) error {
+	var c Credentials
+	if auth.Credential != "" {
+		f.m.Lock()
+		cred, exists := f.credentials[auth.Credential]
+		f.m.Unlock()
+
+		if !exists {
+			return fmt.Errorf("credential %q not found", auth.Credential)
+		}
+		c = cred
+	}
+
+	if auth.Username != "" {
+		c.Username = auth.Username
+	}
+	if auth.PasswordEnv != "" {
+		c.Password = os.Getenv(auth.PasswordEnv)
+	}
+	if auth.TokenEnv != "" {
+		c.Token = os.Getenv(auth.TokenEnv)
+	}
+
+	switch auth.Type {
+	case "basic":
+		if c.Username == "" {
+			return errors.New("basic auth without a username")
+		}
+		req.SetBasicAuth(c.Username, c.Password)
+
+	case "bearer":
+		if c.Token == "" {
+			return errors.New("bearer auth without a token")
+		}
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+
+	default:
+		return fmt.Errorf("unknown auth type %q", auth.Type)
+	}
+
+	return nil
+}
+
+// ClientFor 返回请求数据源使用的客户端
+func (f *Fetcher) ClientFor(feed *Feed) (*http.Client, error) {
+	if feed.Proxy == "" && feed.TLS == nil {
+		return f.Client, nil
+	}
+
+	// 使用代理和 TLS 设置作为键，相同设置的数据源共用同一个客户端
+	key, err := json.Marshal(struct {
+		Proxy string
+		TLS   *TLSConfig
+	}{feed.Proxy, feed.TLS})
+	if err != nil {
+		return nil, err
+	}
+
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	if client, exists := f.clients[string(key)]; exists {
+		return client, nil
+	}
+
+	transport, err := newTransport(feed.Proxy, feed.TLS)
+	if err != nil {
+		return nil, fmt.Errorf("feed %s: %v", feed.Name, err)
+	}
+
+	client := &http.Client{
+		Transport: transport,
+		Timeout:   f.Client.Timeout,
+	}
+
+	if f.clients == nil {
+		f.clients = make(map[string]*http.Client)
+	}
+	f.clients[string(key)] = client
+
+	return client, nil
+}
+
+// newTransport 按照代理和 TLS 设置创建 Transport，其余设置与 http.DefaultTransport 相同
+func newTransport(proxy string, config *TLSConfig) (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if proxy != "" {
+		proxyURL, err := url.Parse(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy %q: %v", proxy, err)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	if config != nil {
+		tlsConfig, err := config.build()
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+
+	return transport, nil
+}
+
+// build 根据设置创建 tls.Config
+func (c *TLSConfig) build() (*tls.Config, error) {
+	config := tls.Config{
+		ServerName:         c.ServerName,
+		InsecureSkipVerify: c.InsecureSkipVerify,
+	}
+
+	if c.CAFile != "" {
+		pem, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, err
+		}
+
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in %s", c.CAFile)
+		}
+		config.RootCAs = pool
+	}
+
+	if c.CertFile != "" || c.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return &config, nil
+}
@@ -0,0 +1,180 @@
+// 这个示例程序测试 Fetcher 按照数据源的设置添加认证信息和选择客户端
+package search_test
+
+import (
+	"encoding/base64"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"notes.goinaction/chapter02/search"
+)
+
+// newFetcher 返回一个读取了测试凭据的 Fetcher
+func newFetcher(t *testing.T) *search.Fetcher {
+	path := filepath.Join(t.TempDir(), "credentials.json")
+	data := `{
+		"intranet": {"username": "alice", "password": "wonderland"},
+		"api": {"token": "file-token"}
+	}`
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal("\tShould write the credentials file.", ballotX, err)
+	}
+
+	f := search.NewFetcher(time.Second)
+	if err := f.LoadCredentials(path); err != nil {
+		t.Fatal("\tShould load the credentials file.", ballotX, err)
+	}
+	return f
+}
+
+// basic 返回 basic 认证的 Authorization 头部
+func basic(username, password string) string {
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password))
+}
+
+// TestAuthorize 确认不同的认证方式会生成正确的 Authorization 头部，凭据缺失时返回错误
+func TestAuthorize(t *testing.T) {
+	t.Setenv("FETCH_TEST_PASSWORD", "from-env")
+	t.Setenv("FETCH_TEST_TOKEN", "env-token")
+
+	var auths = []struct {
+		name string
+		auth *search.Auth
+		want string
+		ok   bool
+	}{
+		{"basic from file", &search.Auth{Type: "basic", Credential: "intranet"}, basic("alice", "wonderland"), true},
+		{"basic with overrides", &search.Auth{Type: "basic", Credential: "intranet", Username: "bob", PasswordEnv: "FETCH_TEST_PASSWORD"}, basic("bob", "from-env"), true},
+		{"basic without file", &search.Auth{Type: "basic", Username: "carol"}, basic("carol", ""), true},
+		{"bearer from file", &search.Auth{Type: "bearer", Credential: "api"}, "Bearer file-token", true},
+		{"bearer from env", &search.Auth{Type: "bearer", Credential: "api", TokenEnv: "FETCH_TEST_TOKEN"}, "Bearer env-token", true},
+		{"unknown credential", &search.Auth{Type: "bearer", Credential: "missing"}, "", false},
+		{"basic without username", &search.Auth{Type: "basic"}, "", false},
+		{"bearer without token", &search.Auth{Type: "bearer", Credential: "intranet"}, "", false},
+		{"unknown type", &search.Auth{Type: "digest", Credential: "intranet"}, "", false},
+	}
+
+	t.Log("Given the need to authorize feed requests.")
+	{
+		f := newFetcher(t)
+
+		for _, a := range auths {
+			t.Logf("\tWhen using %s", a.name)
+			{
+				req, err := f.NewRequest(&search.Feed{Name: "feed", URI: "http://example.com/rss", Auth: a.auth})
+				switch {
+				case !a.ok && err != nil:
+					t.Log("\t\tShould return an error.", checkMark, err)
+				case !a.ok:
+					t.Error("\t\tShould return an error.", ballotX, req.Header.Get("Authorization"))
+				case err != nil:
+					t.Error("\t\tShould set the Authorization header.", ballotX, err)
+				case req.Header.Get("Authorization") == a.want:
+					t.Log("\t\tShould set the Authorization header.", checkMark)
+				default:
+					t.Error("\t\tShould set the Authorization header.", ballotX, req.Header.Get("Authorization"))
+				}
+			}
+		}
+
+		req, err := f.NewRequest(&search.Feed{URI: "http://example.com/rss", Headers: map[string]string{"User-Agent": "custom"}})
+		if err == nil && req.Header.Get("User-Agent") == "custom" {
+			t.Log("\tShould let feed headers override the User-Agent.", checkMark)
+		} else {
+			t.Error("\tShould let feed headers override the User-Agent.", ballotX, err)
+		}
+	}
+}
+
+// TestClientFor 确认相同设置的数据源共用客户端，错误的设置会返回错误
+func TestClientFor(t *testing.T) {
+	t.Log("Given the need to choose a client for each feed.")
+	{
+		f := search.NewFetcher(time.Second)
+
+		if c, err := f.ClientFor(&search.Feed{Headers: map[string]string{"X-Key": "1"}}); err == nil && c == f.Client {
+			t.Log("\tShould use the default client without proxy and TLS settings.", checkMark)
+		} else {
+			t.Error("\tShould use the default client without proxy and TLS settings.", ballotX, err)
+		}
+
+		a, err := f.ClientFor(&search.Feed{Name: "a", Proxy: "http://proxy.example.com:8080"})
+		if err != nil {
+			t.Fatal("\tShould create a client for the proxy.", ballotX, err)
+		}
+		b, _ := f.ClientFor(&search.Feed{Name: "b", Proxy: "http://proxy.example.com:8080"})
+		c, _ := f.ClientFor(&search.Feed{Name: "c", Proxy: "http://proxy.example.com:8080", TLS: &search.TLSConfig{ServerName: "internal"}})
+
+		if a == b && a != f.Client {
+			t.Log("\tShould share the client between feeds with the same settings.", checkMark)
+		} else {
+			t.Error("\tShould share the client between feeds with the same settings.", ballotX)
+		}
+
+		if c != a && c.Timeout == f.Client.Timeout {
+			t.Log("\tShould create another client for different settings.", checkMark)
+		} else {
+			t.Error("\tShould create another client for different settings.", ballotX)
+		}
+
+		if _, err := f.ClientFor(&search.Feed{Proxy: "http://[::1"}); err != nil {
+			t.Log("\tShould reject an invalid proxy.", checkMark)
+		} else {
+			t.Error("\tShould reject an invalid proxy.", ballotX)
+		}
+
+		missing := filepath.Join(t.TempDir(), "missing.pem")
+		if _, err := f.ClientFor(&search.Feed{TLS: &search.TLSConfig{CAFile: missing}}); err != nil {
+			t.Log("\tShould reject a missing CA file.", checkMark)
+		} else {
+			t.Error("\tShould reject a missing CA file.", ballotX)
+		}
+	}
+}
+
+// TestCredentialsFlag 确认 -credentials 参数指定的凭据文件会被加载，没有指定时什么也不做
+func TestCredentialsFlag(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials.json")
+	os.WriteFile(path, []byte(`{"api": {"token": "file-token"}}`), 0600)
+	feed := &search.Feed{Name: "api", URI: "http://example.com/feed", Auth: &search.Auth{Type: "bearer", Credential: "api"}}
+
+	t.Log("Given the need to load credentials named on the command line.")
+	{
+		f := search.NewFetcher(time.Second)
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		load := f.CredentialsFlag(fs)
+		fs.Parse(nil)
+
+		if err := load(); err == nil {
+			t.Log("\tShould do nothing without the flag.", checkMark)
+		} else {
+			t.Error("\tShould do nothing without the flag.", ballotX, err)
+		}
+
+		f = search.NewFetcher(time.Second)
+		fs = flag.NewFlagSet("test", flag.ContinueOnError)
+		load = f.CredentialsFlag(fs)
+		fs.Parse([]string{"-credentials", path})
+
+		if err := load(); err != nil {
+			t.Fatal("\tShould load the credentials file.", ballotX, err)
+		}
+		if req, err := f.NewRequest(feed); err == nil && req.Header.Get("Authorization") == "Bearer file-token" {
+			t.Log("\tShould use the loaded credentials.", checkMark)
+		} else {
+			t.Error("\tShould use the loaded credentials.", ballotX, err)
+		}
+
+		fs = flag.NewFlagSet("test", flag.ContinueOnError)
+		load = search.NewFetcher(time.Second).CredentialsFlag(fs)
+		fs.Parse([]string{"-credentials", filepath.Join(t.TempDir(), "missing.json")})
+		if err := load(); err != nil {
+			t.Log("\tShould report a missing credentials file.", checkMark)
+		} else {
+			t.Error("\tShould report a missing credentials file.", ballotX)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package search
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// earthRadiusKm 地球的平均半径，单位为公里
+const earthRadiusKm = 6371.0
+
+// Point 表示一个地理坐标，使用 WGS84 的纬度和经度，单位为度
+type Point struct {
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
+}
+
+/*
+ParsePoint 解析形如 "45.256 -71.92" 的坐标字符串
+
+GeoRSS 的 georss:point 使用空白分隔纬度和经度，命令行上输入时使用逗号分隔更方便，所以两种写法都接受。
+*/
+func ParsePoint(s string) (*Point, error) {
+	values, err := parseFloats(s, 2)
+	if err != nil {
+		return nil, fmt.Errorf("invalid point %q: %v", s, err)
+	}
+
+	p := Point{Lat: values[0], Lon: values[1]}
+	if !p.valid() {
+		return nil, fmt.Errorf("invalid point %q: out of range", s)
+	}
+
+	return &p, nil
+}
+
+// NewPoint 使用 geo:lat 和 geo:long 两个独立的字段创建坐标
+func NewPoint(lat, lon string) (*Point, error) {
+	return ParsePoint(lat + " " + lon)
+}
+
+// valid 检查纬度和经度是否在合法范围内
+func (p Point) valid() bool {
+	return p.Lat >= -90 && p.Lat <= 90 && p.Lon >= -180 && p.Lon <= 180
+}
+
+// Distance 使用半正矢公式计算两个坐标之间的球面距离，单位为公里
+func Distance(a, b Point) float64 {
+	lat1 := a.Lat * math.Pi / 180
+	lat2 := b.Lat * math.Pi / 180
+	dLat := (b.Lat - a.Lat) * math.Pi / 180
+	dLon := (b.Lon - a.Lon) * math.Pi / 180
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(h))
+}
+
+// Area 定义了一个地理区域，用来判断坐标是否落在区域内
+type Area interface {
+	Contains(p Point) bool
+}
+
+// BoundingBox 是由西南角和东北角围成的矩形区域
+type BoundingBox struct {
+	MinLat float64
+	MinLon float64
+	MaxLat float64
+	MaxLon float64
+}
+
+/*
+ParseBoundingBox 解析形如 "minLat,minLon,maxLat,maxLon" 的矩形区域
+
+如果 minLon 大于 maxLon，表示这个区域跨越了 180 度经线。
+*/
+func ParseBoundingBox(s string) (*BoundingBox, error) {
+	values, err := parseFloats(s, 4)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bounding box %q: %v", s, err)
+	}
+
+	box := BoundingBox{MinLat: values[0], MinLon: values[1], MaxLat: values[2], MaxLon: values[3]}
+	sw, ne := Point{box.MinLat, box.MinLon}, Point{box.MaxLat, box.MaxLon}
+	if !sw.valid() || !ne.valid() || box.MinLat > box.MaxLat {
+		return nil, fmt.Errorf("invalid bounding box %q: out of range", s)
+	}
+
+	return &box, nil
+}
+
+// Contains 实现了 Area 接口
+func (b BoundingBox) Contains(p Point) bool {
+	if p.Lat < b.MinLat || p.Lat > b.MaxLat {
+		return false
+	}
+
+	// 跨越 180 度经线的区域需要分成两段判断
+	if b.MinLon > b.MaxLon {
+		return p.Lon >= b.MinLon || p.Lon <= b.MaxLon
+	}
+	return p.Lon >= b.MinLon && p.Lon <= b.MaxLon
+}
+
+// Radius 是以 Center 为圆心、半径为 Km 公里的圆形区域
+type Radius struct {
+	Center Point
+	Km     float64
+}
+
+// Contains 实现了 Area 接口
+func (r Radius) Contains(p Point) bool {
+	return Distance(r.Center, p) <= r.Km
+}
+
+// parseFloats 把使用空白或逗号分隔的字符串解析成 n 个浮点数
+func parseFloats(s string, n int) ([]float64, error) {
+	fields := strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t' || r == '\n' || r == '\r'
+	})
+	if len(fields) != n {
+		return nil, fmt.Errorf("want %d values, got %d", n, len(fields))
+	}
+
+	values := make([]float64, n)
+	for i, field := range fields {
+		v, err := strconv.ParseFloat(field, 64)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = v
+	}
+
+	return values, nil
+}
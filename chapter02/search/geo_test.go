@@ -0,0 +1,137 @@
+// 这个示例程序使用表组测试坐标和地理区域的解析与判断
+package search_test
+
+import (
+	"math"
+	"testing"
+
+	"notes.goinaction/chapter02/search"
+)
+
+const checkMark = "\u2713"
+const ballotX = "\u2717"
+
+// TestParsePoint 确认坐标可以使用空白或逗号分隔，超出范围的坐标会被拒绝
+func TestParsePoint(t *testing.T) {
+	var points = []struct {
+		value string
+		ok    bool
+		point search.Point
+	}{
+		{"45.256 -71.92", true, search.Point{Lat: 45.256, Lon: -71.92}},
+		{"45.256,-71.92", true, search.Point{Lat: 45.256, Lon: -71.92}},
+		{" 39.9 ,\t116.4 ", true, search.Point{Lat: 39.9, Lon: 116.4}},
+		{"-90 180", true, search.Point{Lat: -90, Lon: 180}},
+		{"91 0", false, search.Point{}},
+		{"0 -181", false, search.Point{}},
+		{"45.256", false, search.Point{}},
+		{"45.256 -71.92 10", false, search.Point{}},
+		{"north east", false, search.Point{}},
+	}
+
+	t.Log("Given the need to parse points.")
+	{
+		for _, p := range points {
+			t.Logf("\tWhen parsing %q", p.value)
+			{
+				point, err := search.ParsePoint(p.value)
+				switch {
+				case !p.ok && err != nil:
+					t.Log("\t\tShould reject the point.", checkMark)
+				case !p.ok:
+					t.Error("\t\tShould reject the point.", ballotX, *point)
+				case err != nil:
+					t.Error("\t\tShould parse the point.", ballotX, err)
+				case *point == p.point:
+					t.Log("\t\tShould parse the point.", checkMark)
+				default:
+					t.Error("\t\tShould parse the point.", ballotX, *point)
+				}
+			}
+		}
+	}
+}
+
+// TestBoundingBox 确认矩形区域的解析和判断，包括跨越 180 度经线的区域
+func TestBoundingBox(t *testing.T) {
+	var boxes = []struct {
+		value  string
+		point  search.Point
+		inside bool
+	}{
+		{"30,100,45,125", search.Point{Lat: 39.9, Lon: 116.4}, true},
+		{"30,100,45,125", search.Point{Lat: 51.5, Lon: -0.12}, false},
+		{"30,100,45,125", search.Point{Lat: 39.9, Lon: 99.9}, false},
+
+		// 跨越 180 度经线：从东经 170 度到西经 170 度
+		{"-30,170,0,-170", search.Point{Lat: -17.7, Lon: 178.0}, true},
+		{"-30,170,0,-170", search.Point{Lat: -14.3, Lon: -169.5}, false},
+		{"-30,170,0,-170", search.Point{Lat: -21.1, Lon: -175.2}, true},
+		{"-30,170,0,-170", search.Point{Lat: -21.1, Lon: 0}, false},
+		{"-30,170,0,-170", search.Point{Lat: 10, Lon: 179}, false},
+	}
+
+	t.Log("Given the need to check points against bounding boxes.")
+	{
+		for _, b := range boxes {
+			t.Logf("\tWhen checking %v against %q", b.point, b.value)
+			{
+				box, err := search.ParseBoundingBox(b.value)
+				if err != nil {
+					t.Fatal("\t\tShould parse the bounding box.", ballotX, err)
+				}
+
+				if box.Contains(b.point) == b.inside {
+					t.Logf("\t\tShould report inside=%v %v", b.inside, checkMark)
+				} else {
+					t.Errorf("\t\tShould report inside=%v %v", b.inside, ballotX)
+				}
+			}
+		}
+
+		for _, value := range []string{"45,100,30,125", "30,100,45", "30,100,95,125", "30,-190,45,125"} {
+			if _, err := search.ParseBoundingBox(value); err != nil {
+				t.Logf("\tShould reject the bounding box %q %v", value, checkMark)
+			} else {
+				t.Errorf("\tShould reject the bounding box %q %v", value, ballotX)
+			}
+		}
+	}
+}
+
+// TestRadius 确认圆形区域使用球面距离判断，包括跨越 180 度经线的情况
+func TestRadius(t *testing.T) {
+	beijing := search.Point{Lat: 39.9042, Lon: 116.4074}
+	shanghai := search.Point{Lat: 31.2304, Lon: 121.4737}
+
+	t.Log("Given the need to check points against a radius.")
+	{
+		// 北京到上海大约 1068 公里
+		if d := search.Distance(beijing, shanghai); math.Abs(d-1068) < 10 {
+			t.Logf("\tShould compute the great circle distance. %v %.0f", checkMark, d)
+		} else {
+			t.Errorf("\tShould compute the great circle distance. %v %.0f", ballotX, d)
+		}
+
+		if (search.Radius{Center: beijing, Km: 1100}).Contains(shanghai) {
+			t.Log("\tShould contain a point inside the radius.", checkMark)
+		} else {
+			t.Error("\tShould contain a point inside the radius.", ballotX)
+		}
+
+		if !(search.Radius{Center: beijing, Km: 1000}).Contains(shanghai) {
+			t.Log("\tShould not contain a point outside the radius.", checkMark)
+		} else {
+			t.Error("\tShould not contain a point outside the radius.", ballotX)
+		}
+
+		// 经线两侧相距 2 度，在赤道上大约 222 公里
+		east := search.Point{Lat: 0, Lon: 179}
+		west := search.Point{Lat: 0, Lon: -179}
+		if (search.Radius{Center: east, Km: 250}).Contains(west) {
+			t.Log("\tShould measure across the antimeridian.", checkMark)
+		} else {
+			t.Error("\tShould measure across the antimeridian.", ballotX, search.Distance(east, west))
+		}
+	}
+}
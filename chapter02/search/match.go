@@ -2,13 +2,27 @@ package search
 
 import (
 	"fmt"
+	"io"
 	"log"
 )
 
 // Result 保存搜索的结果
 type Result struct {
-	Field   string
-	Content string
+	Field   string `json:"field"`
+	Content string `json:"content"`
+
+	// Feed 和 Item 是结果所在的数据源和条目，匹配器没有提供时为 nil
+	Feed *Feed `json:"feed,omitempty"`
+	Item *Item `json:"item,omitempty"`
+}
+
+// Public 返回结果的副本，其中的数据源只保留可以对外输出的字段，参见 Feed.Public
+func (r *Result) Public() *Result {
+	public := *r
+	if r.Feed != nil {
+		public.Feed = r.Feed.Public()
+	}
+	return &public
 }
 
 /*
@@ -21,13 +35,22 @@ Matcher 定义了要实现的新搜索类型的行为
 3. 如果要让一个用户定义的类型实现一个接口，这个用户定义的类型要实现接口类型里声明的所有方法。
 */
 type Matcher interface {
-	Search(feed *Feed, searchTerm string) ([]*Result, error)
+	Search(feed *Feed, searchTerm string, opts *Options) ([]*Result, error)
+}
+
+/*
+Decoder 由能够把数据源文档解码成条目的匹配器实现
+
+健康检查这类不需要搜索、只关心数据源内容的功能，通过类型断言检查匹配器是否实现了这个接口。
+*/
+type Decoder interface {
+	Decode(r io.Reader) ([]*Item, error)
 }
 
 // Match 函数，为每个数据源单独启动 goroutine 来执行这个，函数并发地执行搜索
-func Match(matcher Matcher, feed *Feed, searchTerm string, results chan<- *Result) {
+func Match(matcher Matcher, feed *Feed, searchTerm string, opts *Options, results chan<- *Result) {
 	// 对特定的匹配器执行搜索
-	searchResults, err := matcher.Search(feed, searchTerm)
+	searchResults, err := matcher.Search(feed, searchTerm, opts)
 	if err != nil {
 		log.Println(err)
 		return
@@ -40,7 +63,7 @@ func Match(matcher Matcher, feed *Feed, searchTerm string, results chan<- *Resul
 }
 
 // Display 从每个单独的 goroutine 接收到结果后在终端窗口输出
-func Display(results chan *Result) {
+func Display(results <-chan *Result) {
 	// 通道会一直被阻塞，直到有结果写入
 	// 一旦通道被关闭，for 循环就会终止
 	for result := range results {
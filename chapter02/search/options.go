@@ -0,0 +1,80 @@
+package search
+
+import "time"
+
+// Item 是匹配器从数据源中解析出来的一个条目，搜索条件会在匹配之前作用在它上面
+type Item struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Link        string `json:"link"`
+	GUID        string `json:"guid,omitempty"`
+
+	// Published 是条目的发布时间，数据源没有提供或者无法解析时为零值
+	Published time.Time `json:"published"`
+
+	// Point 是条目的地理坐标，数据源没有提供坐标时为 nil
+	Point *Point `json:"point,omitempty"`
+}
+
+// ID 返回用来区分条目的值，依次使用 guid、链接和标题
+func (i *Item) ID() string {
+	switch {
+	case i.GUID != "":
+		return i.GUID
+	case i.Link != "":
+		return i.Link
+	default:
+		return i.Title
+	}
+}
+
+// Options 保存一次搜索的附加条件，零值或 nil 表示不做任何限制
+type Options struct {
+	// Feeds 挑选参与搜索的数据源，为 nil 时使用全部数据源
+	Feeds *FeedFilter
+
+	// Area 限定条目的地理位置，没有坐标的条目会被跳过
+	Area Area
+
+	// Since 和 Until 限定条目的发布时间范围，零值表示不限制。设置了范围时，没有发布时间的条目会被跳过
+	Since time.Time
+	Until time.Time
+
+	// Cluster 为 true 时，Run 会在匹配之后把近似重复的结果合并，每组只显示一个代表结果
+	Cluster bool
+}
+
+// Accept 报告条目是否满足搜索条件，只有满足条件的条目才会参与匹配
+func (o *Options) Accept(item *Item) bool {
+	if o == nil {
+		return true
+	}
+
+	if !o.Since.IsZero() || !o.Until.IsZero() {
+		if item.Published.IsZero() {
+			return false
+		}
+		if !o.Since.IsZero() && item.Published.Before(o.Since) {
+			return false
+		}
+		if !o.Until.IsZero() && item.Published.After(o.Until) {
+			return false
+		}
+	}
+
+	if o.Area != nil {
+		if item.Point == nil || !o.Area.Contains(*item.Point) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// selectFeeds 返回满足数据源过滤条件的数据源
+func (o *Options) selectFeeds(feeds []*Feed) []*Feed {
+	if o == nil {
+		return feeds
+	}
+	return o.Feeds.Apply(feeds)
+}
@@ -13,8 +13,42 @@ import (
 */
 var matchers = make(map[string]Matcher)
 
-// Run 执行搜索逻辑
-func Run(searchTerm string) {
+// Run 执行搜索逻辑，opts 为 nil 时不对条目做任何限制
+func Run(searchTerm string, opts *Options) {
+	// 获取需要搜索的数据源列表
+	feeds, err := RetrieveFeeds()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// 合并近似重复的结果需要先拿到全部结果
+	if opts != nil && opts.Cluster {
+		clusters := ClusterResults(Find(feeds, searchTerm, opts), DefaultClusterDistance)
+
+		log.Println("Display Result:")
+		DisplayClusters(clusters)
+		return
+	}
+
+	results := Start(feeds, searchTerm, opts)
+
+	log.Println("Display Result:")
+	// 启动函数，显示返回的结果，并且在最后一个结果显示完后返回
+	Display(results)
+}
+
+// Find 搜索给定的数据源，等待所有数据源搜索完成后返回全部结果
+func Find(feeds []*Feed, searchTerm string, opts *Options) []*Result {
+	var found []*Result
+	for result := range Start(feeds, searchTerm, opts) {
+		found = append(found, result)
+	}
+
+	return found
+}
+
+// Start 为每个数据源启动一个 goroutine 执行搜索，所有搜索完成后返回的通道会被关闭
+func Start(feeds []*Feed, searchTerm string, opts *Options) <-chan *Result {
 	/*
 		创建一个无缓冲的通道，接收匹配后的结果
 
@@ -24,11 +58,8 @@ func Run(searchTerm string) {
 	*/
 	results := make(chan *Result)
 
-	// 获取需要搜索的数据源列表
-	feeds, err := RetrieveFeeds()
-	if err != nil {
-		log.Fatal(err)
-	}
+	// 只搜索满足过滤条件的数据源
+	feeds = opts.selectFeeds(feeds)
 
 	/*
 		构造一个 waitGroup，以便处理所有的数据源
@@ -52,18 +83,8 @@ func Run(searchTerm string) {
 		要调用的函数返回多个值，而又不需要其中的某个值，就可以使用下划线标识符将其忽略。
 	*/
 	for _, feed := range feeds {
-		/*
-			获取一个匹配器用于查找
-
-			查找 map 里的键时，有两个选择：要么赋值给一个变量，要么为了精确查找，赋值给两个变量。
-			赋值给两个变量时第一个值和赋值给一个变量时的值一样，是 map 查找的结果值。如果指定了
-			第二个值，就会返回一个布尔标志，来表示查找的键是否存在于 map 里。如果这个键不存在，
-			map 会返回其值类型的零值作为返回值，如果这个键存在，map 会返回键所对应值的副本。
-		*/
-		matcher, exists := matchers[feed.Type]
-		if !exists {
-			matcher = matchers["default"]
-		}
+		// 获取一个匹配器用于查找
+		matcher := Lookup(feed.Type)
 
 		/*
 			使用关键字 go 启动一个 goroutine 来执行搜索
@@ -76,7 +97,7 @@ func Run(searchTerm string) {
 			变量每次调用时值不相同，所以并没有使用闭包的方式访问这两个变量。
 		*/
 		go func(matcher Matcher, feed *Feed) {
-			Match(matcher, feed, searchTerm, results)
+			Match(matcher, feed, searchTerm, opts, results)
 
 			// 每个 goroutine 完成其工作后，递减 WaitGroup 变量的计数值，当这个值递减到 0 时，
 			// 我们就知道所有的工作都做完了。
@@ -93,13 +114,27 @@ func Run(searchTerm string) {
 		*/
 		waitGroup.Wait()
 
-		// 用关闭通道的方式，通知 Display 函数可以退出程序了
+		// 用关闭通道的方式，通知接收结果的一方所有搜索都已经完成
 		close(results)
 	}()
 
-	log.Println("Display Result:")
-	// 启动函数，显示返回的结果，并且在最后一个结果显示完后返回
-	Display(results)
+	return results
+}
+
+// Lookup 返回数据源类型对应的匹配器，没有注册这个类型时返回默认匹配器
+func Lookup(feedType string) Matcher {
+	/*
+		查找 map 里的键时，有两个选择：要么赋值给一个变量，要么为了精确查找，赋值给两个变量。
+		赋值给两个变量时第一个值和赋值给一个变量时的值一样，是 map 查找的结果值。如果指定了
+		第二个值，就会返回一个布尔标志，来表示查找的键是否存在于 map 里。如果这个键不存在，
+		map 会返回其值类型的零值作为返回值，如果这个键存在，map 会返回键所对应值的副本。
+	*/
+	matcher, exists := matchers[feedType]
+	if !exists {
+		matcher = matchers["default"]
+	}
+
+	return matcher
 }
 
 // Register 调用时，会注册一个匹配器，提供给后面的程序使用
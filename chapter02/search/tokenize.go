@@ -0,0 +1,63 @@
+package search
+
+import (
+	"html"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// htmlTag 匹配描述里常见的 HTML 标签
+var htmlTag = regexp.MustCompile(`<[^>]*>`)
+
+/*
+Tokenize 把一段文本拆分成小写的词
+
+1. 描述里的 HTML 标签和实体会先被去掉。
+2. 字母和数字组成的连续片段是一个词，例如 "Covid-19" 会被拆成 "covid" 和 "19"。
+3. 中文没有空格分隔，连续的汉字按照相邻的两个字拆分（二元分词），只有一个字时就作为一个词。
+*/
+func Tokenize(text string) []string {
+	text = html.UnescapeString(htmlTag.ReplaceAllString(text, " "))
+
+	var tokens []string
+	var word []rune
+	var han []rune
+
+	flushWord := func() {
+		if len(word) > 0 {
+			tokens = append(tokens, strings.ToLower(string(word)))
+			word = word[:0]
+		}
+	}
+	flushHan := func() {
+		switch len(han) {
+		case 0:
+		case 1:
+			tokens = append(tokens, string(han))
+		default:
+			for i := 0; i+1 < len(han); i++ {
+				tokens = append(tokens, string(han[i:i+2]))
+			}
+		}
+		han = han[:0]
+	}
+
+	for _, r := range text {
+		switch {
+		case unicode.Is(unicode.Han, r):
+			flushWord()
+			han = append(han, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			flushHan()
+			word = append(word, r)
+		default:
+			flushWord()
+			flushHan()
+		}
+	}
+	flushWord()
+	flushHan()
+
+	return tokens
+}
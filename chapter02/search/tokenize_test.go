@@ -0,0 +1,35 @@
+// 这个示例程序使用表组测试英文和中文文本的分词
+package search_test
+
+import (
+	"reflect"
+	"testing"
+
+	"notes.goinaction/chapter02/search"
+)
+
+// TestTokenize 确认英文按照字母和数字切分，中文按照二元分词切分，HTML 标签和实体会被去掉
+func TestTokenize(t *testing.T) {
+	var texts = []struct {
+		text   string
+		tokens []string
+	}{
+		{"Covid-19 cases <b>rise</b> &amp; fall", []string{"covid", "19", "cases", "rise", "fall"}},
+		{"Senate's BUDGET", []string{"senate", "s", "budget"}},
+		{"经济增长", []string{"经济", "济增", "增长"}},
+		{"中 国", []string{"中", "国"}},
+		{"GDP增长3%", []string{"gdp", "增长", "3"}},
+		{"<p>&nbsp;</p>", nil},
+	}
+
+	t.Log("Given the need to split text into terms.")
+	{
+		for _, tt := range texts {
+			if tokens := search.Tokenize(tt.text); reflect.DeepEqual(tokens, tt.tokens) {
+				t.Logf("\tShould split %q %v", tt.text, checkMark)
+			} else {
+				t.Errorf("\tShould split %q %v %q", tt.text, ballotX, tokens)
+			}
+		}
+	}
+}
@@ -0,0 +1,113 @@
+// 这个示例程序统计所有数据源里最近一个时间窗口的词频，输出相对基线出现频率升高的词
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	_ "notes.goinaction/chapter02/matchers"
+	"notes.goinaction/chapter02/search"
+	"notes.goinaction/chapter02/trends"
+)
+
+// 命令行参数
+var (
+	dataFile     = flag.String("data", "data/data.json", "数据源列表文件")
+	tags         = flag.String("tag", "", "只统计带有这些标签的数据源，多个标签使用逗号分隔")
+	window       = flag.Duration("window", 24*time.Hour, "时间窗口的长度")
+	baselineFile = flag.String("baseline", "data/baseline.json", "基线文件，保存每个词在一个时间窗口里的平均频率")
+	update       = flag.Bool("update", false, "把最近一个时间窗口的统计合并进基线文件")
+	minCount     = flag.Int("min-count", 3, "词在最近一个时间窗口里至少出现的次数")
+	minRatio     = flag.Float64("min-ratio", 2, "词的出现频率相对基线至少升高的倍数")
+	top          = flag.Int("top", 20, "最多输出多少个词，0 表示不限制")
+	asJSON       = flag.Bool("json", false, "以 JSON 格式输出")
+
+	loadCredentials = search.DefaultFetcher.CredentialsFlag(flag.CommandLine)
+)
+
+// main 程序入口
+func main() {
+	flag.Parse()
+
+	if err := loadCredentials(); err != nil {
+		log.Fatalln(err)
+	}
+
+	list, err := search.LoadFeedList(*dataFile)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	filter := search.FeedFilter{}
+	for _, tag := range strings.Split(*tags, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			filter.Tags = append(filter.Tags, tag)
+		}
+	}
+
+	now := time.Now()
+	stop := trends.DefaultStopWords()
+	items := trends.Fetch(filter.Apply(list.All()))
+	windows := trends.Windows(items, *window, now, stop)
+
+	// 第一个窗口就是最近的时间窗口，没有任何条目时当前窗口是空的
+	current := &trends.Window{Start: now.Add(-*window), Counts: trends.Counts{}}
+	var older []*trends.Window
+	if len(windows) > 0 {
+		current = windows[0]
+		older = windows[1:]
+	}
+
+	baseline, err := trends.LoadBaseline(*baselineFile)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	// 还没有保存过基线时，使用数据源里更早的条目临时计算一个基线，从最旧的窗口开始合并，没有条目的窗口算作 0
+	if baseline.Windows == 0 {
+		for i := len(older) - 1; i >= 0; i-- {
+			baseline.Update(older[i], older[i].Start)
+		}
+	}
+
+	spikes := trends.Spikes(current, baseline, *minCount, *minRatio)
+	if *top > 0 && len(spikes) > *top {
+		spikes = spikes[:*top]
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "    ")
+		err = enc.Encode(spikes)
+	} else {
+		err = writeTable(spikes)
+	}
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	if *update {
+		baseline.Update(current, now)
+		if err := baseline.Save(*baselineFile); err != nil {
+			log.Fatalln(err)
+		}
+	}
+}
+
+// writeTable 将升高的词以对齐的表格写到标准输出
+func writeTable(spikes []trends.Trend) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "TERM\tCOUNT\tBASELINE\tRATIO")
+
+	for _, t := range spikes {
+		fmt.Fprintf(w, "%s\t%d\t%.2f\t%.2f\n", t.Term, t.Count, t.Baseline, t.Ratio)
+	}
+
+	return w.Flush()
+}
@@ -0,0 +1,85 @@
+package trends
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// englishStopWords 是英文里出现频率很高、但是没有实际意义的词
+var englishStopWords = []string{
+	"a", "about", "above", "after", "again", "against", "all", "also", "am", "an", "and", "any", "are",
+	"as", "at", "be", "because", "been", "before", "being", "below", "between", "both", "but", "by",
+	"can", "could", "did", "do", "does", "doing", "down", "during", "each", "few", "for", "from",
+	"further", "had", "has", "have", "having", "he", "her", "here", "hers", "herself", "him",
+	"himself", "his", "how", "i", "if", "in", "into", "is", "it", "its", "itself", "just", "me",
+	"more", "most", "my", "myself", "new", "no", "nor", "not", "now", "of", "off", "on", "once",
+	"one", "only", "or", "other", "our", "ours", "ourselves", "out", "over", "own", "said", "same",
+	"says", "she", "should", "so", "some", "such", "than", "that", "the", "their", "theirs", "them",
+	"themselves", "then", "there", "these", "they", "this", "those", "through", "to", "too", "two",
+	"under", "until", "up", "us", "very", "was", "we", "were", "what", "when", "where", "which",
+	"while", "who", "whom", "why", "will", "with", "would", "you", "your", "yours", "yourself",
+	"yourselves", "read", "video", "watch", "s", "t", "nbsp", "amp", "quot",
+}
+
+// chineseStopWords 是中文里出现频率很高、但是没有实际意义的词
+var chineseStopWords = []string{
+	"我们", "你们", "他们", "她们", "它们", "自己", "这个", "那个", "这些", "那些", "这样", "那样",
+	"一个", "一些", "没有", "不是", "就是", "还是", "可以", "因为", "所以", "但是", "如果", "虽然",
+	"而且", "以及", "或者", "已经", "正在", "通过", "进行", "表示", "认为", "今天", "目前", "其中",
+	"什么", "怎么", "记者", "报道", "消息",
+}
+
+// chineseStopChars 是中文里的虚词，含有这些字的二元分词大多跨越了两个词，没有统计意义
+var chineseStopChars = "的了是在和也就都而及与着或之被把为这那个有对等从向将于其但并让给"
+
+// StopWords 保存了统计时需要忽略的词
+type StopWords struct {
+	words map[string]bool
+	chars map[rune]bool
+}
+
+// DefaultStopWords 返回包含英文和中文停用词的 StopWords
+func DefaultStopWords() *StopWords {
+	s := StopWords{
+		words: make(map[string]bool),
+		chars: make(map[rune]bool),
+	}
+	s.Add(englishStopWords...)
+	s.Add(chineseStopWords...)
+	for _, r := range chineseStopChars {
+		s.chars[r] = true
+	}
+
+	return &s
+}
+
+// Add 添加停用词
+func (s *StopWords) Add(words ...string) {
+	for _, w := range words {
+		s.words[w] = true
+	}
+}
+
+// Stop 报告一个词是否应该被忽略：停用词、纯数字、单个字母，以及含有中文虚词的二元分词
+func (s *StopWords) Stop(token string) bool {
+	if s.words[token] {
+		return true
+	}
+
+	if utf8.RuneCountInString(token) == 1 {
+		r, _ := utf8.DecodeRuneInString(token)
+		return !unicode.Is(unicode.Han, r) || s.chars[r]
+	}
+
+	digits := true
+	for _, r := range token {
+		if s.chars[r] {
+			return true
+		}
+		if !unicode.IsDigit(r) {
+			digits = false
+		}
+	}
+
+	return digits
+}
@@ -0,0 +1,46 @@
+// 这个示例程序使用表组测试停用词的规则
+package trends_test
+
+import (
+	"testing"
+
+	"notes.goinaction/chapter02/trends"
+)
+
+// TestStop 确认停用词、单个字母、纯数字和含有中文虚词的二元分词会被忽略
+func TestStop(t *testing.T) {
+	var tokens = []struct {
+		token string
+		stop  bool
+	}{
+		{"the", true},
+		{"senate", false},
+		{"x", true},
+		{"2021", true},
+		{"１２", true},
+		{"covid19", false},
+		{"我们", true},
+		{"经济", false},
+		{"的人", true},
+		{"增长", false},
+		{"选", false},
+		{"的", true},
+
+		// nasa 是使用 Add 添加的停用词
+		{"nasa", true},
+	}
+
+	stop := trends.DefaultStopWords()
+	stop.Add("nasa")
+
+	t.Log("Given the need to skip meaningless terms.")
+	{
+		for _, tt := range tokens {
+			if stop.Stop(tt.token) == tt.stop {
+				t.Logf("\tShould report %q as stop=%v %v", tt.token, tt.stop, checkMark)
+			} else {
+				t.Errorf("\tShould report %q as stop=%v %v", tt.token, tt.stop, ballotX)
+			}
+		}
+	}
+}
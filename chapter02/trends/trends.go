@@ -0,0 +1,217 @@
+// Package trends 包统计数据源条目里的词频，找出出现频率突然升高的词
+package trends
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"notes.goinaction/chapter02/search"
+)
+
+// maxBaselineWindows 是基线最多累计的时间窗口数，超过之后新窗口的权重不再继续减小
+const maxBaselineWindows = 30
+
+// Counts 记录每个词在多少个条目里出现过
+type Counts map[string]int
+
+// Window 是一个时间窗口内的条目统计
+type Window struct {
+	Start  time.Time
+	Items  int
+	Counts Counts
+}
+
+// Baseline 是每个词在一个时间窗口里的平均频率，也就是包含这个词的条目所占的比例，保存在文件里供下一次比较
+type Baseline struct {
+	Windows int                `json:"windows"`
+	Updated time.Time          `json:"updated"`
+	Terms   map[string]float64 `json:"terms"`
+}
+
+// Trend 是一个出现频率升高的词，Baseline 是按照当前窗口的条目数换算的基线出现次数
+type Trend struct {
+	Term     string  `json:"term"`
+	Count    int     `json:"count"`
+	Baseline float64 `json:"baseline"`
+	Ratio    float64 `json:"ratio"`
+}
+
+// Fetch 并发地取回所有数据源的条目，某个数据源失败时只记录日志
+func Fetch(feeds []*search.Feed) []*search.Item {
+	var (
+		m     sync.Mutex
+		wg    sync.WaitGroup
+		items []*search.Item
+	)
+
+	wg.Add(len(feeds))
+	for _, feed := range feeds {
+		go func(feed *search.Feed) {
+			defer wg.Done()
+
+			found, err := search.Retrieve(feed)
+			if err != nil {
+				log.Println(feed.Name, err)
+				return
+			}
+
+			m.Lock()
+			items = append(items, found...)
+			m.Unlock()
+		}(feed)
+	}
+	wg.Wait()
+
+	return items
+}
+
+// Count 统计每个词在多少个条目里出现过，同一个条目里重复出现的词只计算一次
+func Count(items []*search.Item, stop *StopWords) Counts {
+	counts := make(Counts)
+	for _, item := range items {
+		seen := make(map[string]bool)
+		for _, token := range search.Tokenize(item.Title + " " + item.Description) {
+			if seen[token] || stop.Stop(token) {
+				continue
+			}
+			seen[token] = true
+			counts[token]++
+		}
+	}
+
+	return counts
+}
+
+/*
+Windows 按照发布时间把条目划分到长度为 size、截止到 now 的时间窗口里，返回的窗口从新到旧排列
+
+第一个窗口是 (now-size, now]，没有发布时间的条目被认为是刚刚发布的，放进第一个窗口。
+从第一个窗口到最旧的条目所在的窗口都会出现在结果里，没有条目的窗口的 Items 为 0，这样基线会把它们算作 0。
+没有条目时返回 nil。
+*/
+func Windows(items []*search.Item, size time.Duration, now time.Time, stop *StopWords) []*Window {
+	buckets := make(map[int][]*search.Item)
+	for _, item := range items {
+		n := 0
+		if !item.Published.IsZero() {
+			age := now.Sub(item.Published)
+			if age < 0 {
+				age = 0
+			}
+			n = int(age / size)
+		}
+		buckets[n] = append(buckets[n], item)
+	}
+
+	oldest := -1
+	for n := range buckets {
+		oldest = max(oldest, n)
+	}
+
+	var windows []*Window
+	for n := 0; n <= oldest; n++ {
+		windows = append(windows, &Window{
+			Start:  now.Add(-time.Duration(n+1) * size),
+			Items:  len(buckets[n]),
+			Counts: Count(buckets[n], stop),
+		})
+	}
+
+	return windows
+}
+
+// LoadBaseline 读取保存的基线，文件不存在时返回空的基线
+func LoadBaseline(path string) (*Baseline, error) {
+	b := Baseline{Terms: make(map[string]float64)}
+
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return &b, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	if err := json.NewDecoder(file).Decode(&b); err != nil {
+		return nil, err
+	}
+	if b.Terms == nil {
+		b.Terms = make(map[string]float64)
+	}
+
+	return &b, nil
+}
+
+// Save 把基线写入文件
+func (b *Baseline) Save(path string) error {
+	data, err := json.MarshalIndent(b, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, data, 0644)
+}
+
+/*
+Update 把一个窗口的统计合并进基线，基线的值是各个窗口里词频的平均值
+
+词频是包含这个词的条目数除以窗口里的条目数，这样条目多的窗口不会让基线偏高。没有条目的窗口里所有词的频率都是 0。
+*/
+func (b *Baseline) Update(w *Window, now time.Time) {
+	n := float64(b.Windows)
+
+	for term, avg := range b.Terms {
+		b.Terms[term] = avg * n / (n + 1)
+	}
+	if w.Items > 0 {
+		for term, count := range w.Counts {
+			b.Terms[term] += float64(count) / float64(w.Items) / (n + 1)
+		}
+	}
+
+	if b.Windows < maxBaselineWindows {
+		b.Windows++
+	}
+	b.Updated = now
+}
+
+/*
+Spikes 返回当前窗口里出现频率相对基线升高的词，按照升高的倍数从大到小排列
+
+基线的词频先乘以当前窗口的条目数，换算成这个窗口里的期望次数，所以窗口里的条目变多时不会所有的词都升高。
+倍数使用 (count+1)/(expected+1) 计算，这样基线里没有出现过的词也有一个有限的倍数。
+只有出现次数不少于 minCount、倍数不小于 minRatio 的词才会被返回。
+*/
+func Spikes(current *Window, b *Baseline, minCount int, minRatio float64) []Trend {
+	var trends []Trend
+	for term, count := range current.Counts {
+		if count < minCount {
+			continue
+		}
+
+		base := b.Terms[term] * float64(current.Items)
+		ratio := (float64(count) + 1) / (base + 1)
+		if ratio < minRatio {
+			continue
+		}
+
+		trends = append(trends, Trend{Term: term, Count: count, Baseline: base, Ratio: ratio})
+	}
+
+	sort.Slice(trends, func(i, j int) bool {
+		if trends[i].Ratio != trends[j].Ratio {
+			return trends[i].Ratio > trends[j].Ratio
+		}
+		if trends[i].Count != trends[j].Count {
+			return trends[i].Count > trends[j].Count
+		}
+		return trends[i].Term < trends[j].Term
+	})
+
+	return trends
+}
@@ -0,0 +1,151 @@
+// 这个示例程序使用表组测试词频的统计、时间窗口的划分、基线的平均和升高的词的判断
+package trends_test
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"notes.goinaction/chapter02/search"
+	"notes.goinaction/chapter02/trends"
+)
+
+const checkMark = "\u2713"
+const ballotX = "\u2717"
+
+// TestCount 确认每个词在一个条目里只计算一次，并且停用词不会被统计
+func TestCount(t *testing.T) {
+	items := []*search.Item{
+		{Title: "Senate passes budget", Description: "senate vote"},
+		{Title: "The budget"},
+		{Title: "2021 a 选举"},
+	}
+	want := trends.Counts{"senate": 1, "passes": 1, "budget": 2, "vote": 1, "选举": 1}
+
+	t.Log("Given the need to count the items each term appears in.")
+	{
+		if counts := trends.Count(items, trends.DefaultStopWords()); reflect.DeepEqual(counts, want) {
+			t.Log("\tShould count each term once per item and skip stop words.", checkMark)
+		} else {
+			t.Error("\tShould count each term once per item and skip stop words.", ballotX, counts)
+		}
+	}
+}
+
+// TestWindows 确认条目按照发布时间划分到窗口里，没有条目的窗口也会保留
+func TestWindows(t *testing.T) {
+	now := time.Date(2021, 4, 17, 12, 0, 0, 0, time.UTC)
+	items := []*search.Item{
+		{Title: "budget", Published: now.Add(-time.Hour)},
+		{Title: "budget vote"},
+		{Title: "election", Published: now.Add(-50 * time.Hour)},
+		{Title: "future", Published: now.Add(time.Hour)},
+	}
+
+	var windows = []struct {
+		start time.Time
+		items int
+	}{
+		{now.Add(-24 * time.Hour), 3},
+		{now.Add(-48 * time.Hour), 0},
+		{now.Add(-72 * time.Hour), 1},
+	}
+
+	t.Log("Given the need to split items into windows.")
+	{
+		got := trends.Windows(items, 24*time.Hour, now, trends.DefaultStopWords())
+		if len(got) != len(windows) {
+			t.Fatal("\tShould return one window per day up to the oldest item.", ballotX, len(got))
+		}
+		t.Log("\tShould return one window per day up to the oldest item.", checkMark)
+
+		for i, w := range windows {
+			if got[i].Start.Equal(w.start) && got[i].Items == w.items {
+				t.Logf("\tShould put %d items in window #%d. %v", w.items, i, checkMark)
+			} else {
+				t.Errorf("\tShould put %d items in window #%d. %v %v %d", w.items, i, ballotX, got[i].Start, got[i].Items)
+			}
+		}
+
+		// 没有发布时间的条目和未来的条目都算作刚刚发布
+		if got[0].Counts["budget"] == 2 {
+			t.Log("\tShould put undated items in the current window.", checkMark)
+		} else {
+			t.Error("\tShould put undated items in the current window.", ballotX, got[0].Counts)
+		}
+
+		if got := trends.Windows(nil, 24*time.Hour, now, trends.DefaultStopWords()); len(got) == 0 {
+			t.Log("\tShould return no windows without items.", checkMark)
+		} else {
+			t.Error("\tShould return no windows without items.", ballotX, len(got))
+		}
+	}
+}
+
+// TestBaselineUpdate 确认基线是各个窗口里词频的平均值，没有条目的窗口算作 0
+func TestBaselineUpdate(t *testing.T) {
+	now := time.Date(2021, 4, 17, 12, 0, 0, 0, time.UTC)
+	var updates = []struct {
+		window *trends.Window
+		want   map[string]float64
+	}{
+		{&trends.Window{Items: 4, Counts: trends.Counts{"go": 2}}, map[string]float64{"go": 0.5}},
+		{&trends.Window{Counts: trends.Counts{}}, map[string]float64{"go": 0.25}},
+		{&trends.Window{Items: 2, Counts: trends.Counts{"go": 2, "rust": 1}}, map[string]float64{"go": 0.5, "rust": 1.0 / 6}},
+	}
+
+	t.Log("Given the need to average term frequencies into a baseline.")
+	{
+		b, _ := trends.LoadBaseline("missing.json")
+		for i, u := range updates {
+			b.Update(u.window, now)
+
+			ok := b.Windows == i+1
+			for term, want := range u.want {
+				ok = ok && math.Abs(b.Terms[term]-want) < 1e-9
+			}
+			if ok {
+				t.Logf("\tShould average %d windows. %v", i+1, checkMark)
+			} else {
+				t.Errorf("\tShould average %d windows. %v %v", i+1, ballotX, b.Terms)
+			}
+		}
+	}
+}
+
+// TestSpikes 确认升高的词按照倍数排列，并且比较的是词频而不是次数
+func TestSpikes(t *testing.T) {
+	b := &trends.Baseline{Windows: 10, Terms: map[string]float64{"go": 0.1, "rust": 0.5}}
+
+	t.Log("Given the need to find terms that spike above the baseline.")
+	{
+		current := &trends.Window{Items: 20, Counts: trends.Counts{"go": 6, "rust": 10, "zig": 3, "odin": 3, "tiny": 1}}
+		spikes := trends.Spikes(current, b, 3, 2)
+
+		var terms []string
+		for _, s := range spikes {
+			terms = append(terms, s.Term)
+		}
+		if want := []string{"odin", "zig", "go"}; reflect.DeepEqual(terms, want) {
+			t.Log("\tShould order the spikes by ratio, count and term.", checkMark)
+		} else {
+			t.Error("\tShould order the spikes by ratio, count and term.", ballotX, terms)
+		}
+
+		// go 的期望次数是 0.1*20=2，倍数是 (6+1)/(2+1)
+		if len(spikes) == 3 && spikes[2].Baseline == 2 && math.Abs(spikes[2].Ratio-7.0/3) < 1e-9 {
+			t.Log("\tShould scale the baseline to the window size.", checkMark)
+		} else {
+			t.Error("\tShould scale the baseline to the window size.", ballotX, spikes)
+		}
+
+		// 窗口里的条目变多时，次数升高但是频率没有变化
+		busy := &trends.Window{Items: 40, Counts: trends.Counts{"go": 8}}
+		if spikes := trends.Spikes(busy, b, 3, 2); len(spikes) == 0 {
+			t.Log("\tShould not report a term whose frequency did not rise.", checkMark)
+		} else {
+			t.Error("\tShould not report a term whose frequency did not rise.", ballotX, spikes)
+		}
+	}
+}
@@ -2,7 +2,7 @@
 package main
 
 import (
-	"io"
+	"context"
 	"log"
 	"math/rand"
 	"sync"
@@ -15,6 +15,7 @@ import (
 const (
 	maxGoroutines   = 25 // 要使用的 goroutine 的数量
 	pooledResources = 2  // 池中的资源的数量
+	maxConnections  = 4  // 同时打开的连接的最大数量
 )
 
 // dbConnection 模拟要共享的资源
@@ -32,7 +33,7 @@ func (dbConn *dbConnection) Close() error {
 var idCounter int32
 
 // createConnection 是一个工厂函数，当需要一个新连接时，资源池会调用这个函数
-func createConnection() (io.Closer, error) {
+func createConnection() (*dbConnection, error) {
 	id := atomic.AddInt32(&idCounter, 1)
 	log.Println("Create: New Connection", id)
 
@@ -50,6 +51,23 @@ func main() {
 		log.Println(err)
 	}
 
+	// 记录池的每个操作
+	p.SetLogger(log.Default())
+
+	// 限制同时打开的连接数量，其余的查询排队等待
+	p.SetMaxOpen(maxConnections)
+
+	// 启动时预先创建连接，创建失败时最多重试 3 次
+	p.SetFactoryRetry(3, 100*time.Millisecond)
+	p.SetMinIdle(pooledResources)
+	if err := p.WarmUp(context.Background()); err != nil {
+		log.Println(err)
+	}
+
+	// 连接最多使用 10 分钟，空闲超过 1 分钟的连接会被关闭
+	p.SetMaxLifetime(10 * time.Minute)
+	p.SetMaxIdleTime(time.Minute)
+
 	// 使用池里的连接来完成查询
 	for query := 0; query < maxGoroutines; query++ {
 		// 每个 goroutine 需要自己复制一份要查询值的副本，不然所有的查询会共享同一个查询变量
@@ -62,24 +80,44 @@ func main() {
 	// 等待 goroutine 结束
 	wg.Wait()
 
-	// 关闭池
+	// 输出池的统计数据
+	stats := p.Stats()
+	log.Printf("Stats: open=%d idle=%d in-use=%d waits=%d wait-time=%v factory-errors=%d closed-full=%d closed-stale=%d closed-forced=%d\n",
+		stats.Open, stats.Idle, stats.InUse, stats.WaitCount, stats.WaitDuration,
+		stats.FactoryErrors, stats.ClosedFull, stats.ClosedStale, stats.ClosedForced)
+
+	// 关闭池，最多等待 5 秒让正在使用的连接被放回
 	log.Println("Shutdown Program.")
-	p.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := p.Close(ctx); err != nil {
+		log.Println(err)
+	}
 }
 
 // performQueries 用来测试连接的资源池
-func performQueries(query int, p *pool.Pool) {
-	// 从池里请求一个连接
-	conn, err := p.Acquire()
+func performQueries(query int, p *pool.Pool[*dbConnection]) {
+	// 从池里请求一个连接，最多等待 5 秒
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	conn, err := p.Acquire(ctx)
 	if err != nil {
 		log.Println(err)
 		return
 	}
 
-	// 将该连接释放回池里
-	defer p.Release(conn)
-
 	// 用等待来模拟查询响应
 	time.Sleep(time.Duration(rand.Intn(1000)) * time.Millisecond)
-	log.Printf("QID[%d] CID[%d]\n", query, conn.(*dbConnection).ID)
+	log.Printf("QID[%d] CID[%d]\n", query, conn.ID)
+
+	// 模拟查询时连接断开，坏掉的连接不再放回池里
+	if query%10 == 9 {
+		log.Printf("QID[%d] CID[%d] Broken\n", query, conn.ID)
+		p.Discard(conn)
+		return
+	}
+
+	// 将该连接释放回池里
+	p.Release(conn)
 }
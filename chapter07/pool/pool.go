@@ -1,18 +1,142 @@
+/*
+Package pool 包管理一组可以在多个 goroutine 之间共享的资源，例如数据库连接
+
+池只有一种类型 Pool[T]，T 是实现了 io.Closer 的资源类型，所有操作都是它的方法：
+
+	p, err := pool.New(createConnection, 2) // 创建池，最多保留 2 个空闲资源
+	conn, err := p.Acquire(ctx)             // 取出资源，等待时受 ctx 的限制
+	p.Release(conn)                         // 用完之后放回
+	p.Discard(conn)                         // 或者在资源坏掉时关闭它，让出它的名额
+	err = p.Close(ctx)                      // 关闭池，等待正在使用的资源被放回
+
+工厂函数返回 io.Closer 时得到 *Pool[io.Closer]，使用方法完全一样。
+*/
 package pool
 
 import (
+	"context"
 	"errors"
 	"io"
-	"log"
 	"sync"
+	"time"
 )
 
-// Pool 管理一组可以安全地在多个 goroutine 间共享的资源。被管理的资源必须实现 io.Closer 接口
-type Pool struct {
-	m         sync.Mutex
-	resources chan io.Closer
-	factory   func() (io.Closer, error)
-	closed    bool
+/*
+Resource 是 Pool 可以管理的资源
+
+池用资源本身作为键记录它的创建时间和是否正在使用，所以资源必须是可以比较的类型，通常是指针。
+T 是接口类型（例如 io.Closer）时，编译器无法检查工厂函数返回的动态类型，切片这类不能作为 map
+键的资源会被马上关闭，Acquire 返回 ErrUnhashable。
+*/
+type Resource interface {
+	comparable
+	io.Closer
+}
+
+/*
+Pool 管理一组类型为 T 的资源，可以安全地在多个 goroutine 间共享。Acquire 直接返回 T，调用者不需要再做类型断言
+
+maxIdle 限制池里最多保留多少个空闲资源，maxOpen 限制同时存在的资源总数（包括空闲的和正在使用的）。
+资源总数达到 maxOpen 时，Acquire 会阻塞，直到有资源被释放。
+*/
+type Pool[T Resource] struct {
+	m       sync.Mutex
+	idle    []idleResource[T]
+	factory func() (T, error)
+	closed  bool
+
+	maxIdle int
+	maxOpen int
+
+	// numOpen 是已经创建、还没有关闭的资源数量，包括正在创建的资源
+	numOpen int
+
+	// inUse 记录已经交给调用者、还没有放回的资源
+	inUse map[T]struct{}
+
+	// drained 在池关闭并且所有资源都已经关闭后关闭
+	drained     chan struct{}
+	drainClosed bool
+
+	// waiters 是按照先来后到排队等待资源的 Acquire
+	waiters []chan grant[T]
+
+	// validate 检查资源是否还能使用，maxLifetime 和 maxIdleTime 为 0 时不限制
+	validate    func(T) error
+	maxLifetime time.Duration
+	maxIdleTime time.Duration
+
+	// created 记录每个资源的创建时间
+	created map[T]time.Time
+
+	// reaping 表示清理过期资源的 goroutine 正在运行，stop 在池关闭时关闭
+	reaping bool
+	stop    chan struct{}
+
+	// minIdle 是池里至少保留的空闲资源数量，filling 是正在为空闲队列创建的资源数量
+	minIdle int
+	filling int
+
+	// fill 通知后台 goroutine 检查是否需要补充空闲资源，filler 表示这个 goroutine 正在运行
+	fill   chan struct{}
+	filler bool
+
+	// retries 是工厂函数失败后重试的次数，backoff 是第一次重试前等待的时间，之后每次翻倍
+	retries int
+	backoff time.Duration
+
+	// logger 记录池的每个操作，为 nil 时不记录
+	logger Logger
+
+	// 统计数据，参见 Stats
+	waitCount     int64
+	waitDuration  time.Duration
+	factoryErrors int64
+	closedFull    int64
+	closedStale   int64
+	closedForced  int64
+}
+
+// Logger 记录池的操作，*log.Logger 实现了这个接口
+type Logger interface {
+	Println(v ...interface{})
+}
+
+// Stats 是池的统计数据
+type Stats struct {
+	// Open 是已经创建、还没有关闭的资源数量，包括正在创建的资源。Idle 是空闲的资源数量，
+	// InUse 是已经交给调用者、还没有放回的资源数量
+	Open  int
+	Idle  int
+	InUse int
+
+	// WaitCount 是因为资源总数达到上限而等待的次数，WaitDuration 是等待的总时间
+	WaitCount    int64
+	WaitDuration time.Duration
+
+	// FactoryErrors 是工厂函数返回错误的次数
+	FactoryErrors int64
+
+	// ClosedFull 是因为空闲队列已满而关闭的资源数量
+	ClosedFull int64
+
+	// ClosedStale 是因为过期、空闲太久、检查失败或者调用 Discard 而关闭的资源数量
+	ClosedStale int64
+
+	// ClosedForced 是 Close 等待超时后被强制关闭的正在使用的资源数量
+	ClosedForced int64
+}
+
+// grant 是交给排队的 Acquire 的资源，create 为 true 时没有资源，只是让出了一个名额，可以创建一个新资源
+type grant[T Resource] struct {
+	r      T
+	create bool
+}
+
+// idleResource 是一个空闲的资源和它开始空闲的时间
+type idleResource[T Resource] struct {
+	r     T
+	since time.Time
 }
 
 /*
@@ -22,63 +146,506 @@ ErrPoolClosed 表示请求（Acquire）了一个已经关闭的池
 */
 var ErrPoolClosed = errors.New("pool has been closed")
 
-// New 创建一个用来管理资源的池。这个池需要一个可以分配新资源的函数，并规定池的大小
-func New(fn func() (io.Closer, error), size uint) (*Pool, error) {
+// ErrUnhashable 表示工厂函数返回的资源不能作为 map 的键，池无法记录它，参见 Resource
+var ErrUnhashable = errors.New("resource is not hashable")
+
+// New 创建一个管理类型为 T 的资源的池，size 是最多保留的空闲资源数量
+func New[T Resource](fn func() (T, error), size uint) (*Pool[T], error) {
 	if size <= 0 {
 		// 因为这是这个函数唯 一可能返回的错误值，所以不需要为这个错误单独创建和使用一个 error 接口变量。
 		return nil, errors.New("size value tool small")
 	}
 
-	return &Pool{
-		factory:   fn,
-		resources: make(chan io.Closer, size),
+	return &Pool[T]{
+		factory: fn,
+		maxIdle: int(size),
+		created: make(map[T]time.Time),
+		inUse:   make(map[T]struct{}),
+		drained: make(chan struct{}),
+		stop:    make(chan struct{}),
+		fill:    make(chan struct{}, 1),
 	}, nil
 }
 
-// Acquire 从池中获取一个资源
-func (p *Pool) Acquire() (io.Closer, error) {
+// SetLogger 设置记录池的操作的 Logger，例如 log.Default()，必须在使用池之前调用
+func (p *Pool[T]) SetLogger(l Logger) {
+	p.logger = l
+}
+
+// log 使用设置的 Logger 记录一个操作
+func (p *Pool[T]) log(v ...interface{}) {
+	if p.logger != nil {
+		p.logger.Println(v...)
+	}
+}
+
+// Stats 返回池当前的统计数据
+func (p *Pool[T]) Stats() Stats {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	return Stats{
+		Open:          p.numOpen,
+		Idle:          len(p.idle),
+		InUse:         len(p.inUse),
+		WaitCount:     p.waitCount,
+		WaitDuration:  p.waitDuration,
+		FactoryErrors: p.factoryErrors,
+		ClosedFull:    p.closedFull,
+		ClosedStale:   p.closedStale,
+		ClosedForced:  p.closedForced,
+	}
+}
+
+// SetMaxOpen 设置同时存在的资源的最大数量，0 表示不限制。小于空闲资源的最大数量时，空闲资源的最大数量和 minIdle 会随之减小
+func (p *Pool[T]) SetMaxOpen(n int) {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	if n < 0 {
+		n = 0
+	}
+	p.maxOpen = n
+	if n > 0 && p.maxIdle > n {
+		p.maxIdle = n
+	}
+	p.clampMinIdle()
+	p.shrink()
+}
+
+// SetMaxIdle 设置池里最多保留的空闲资源数量，多余的空闲资源会被关闭，minIdle 也不会超过这个数量
+func (p *Pool[T]) SetMaxIdle(n int) {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	if n < 0 {
+		n = 0
+	}
+	if p.maxOpen > 0 && n > p.maxOpen {
+		n = p.maxOpen
+	}
+	p.maxIdle = n
+	p.clampMinIdle()
+	p.shrink()
+}
+
+// SetValidator 设置检查资源是否还能使用的函数，Acquire 取出空闲资源和 Release 放回资源时都会调用，返回错误的资源会被关闭
+func (p *Pool[T]) SetValidator(fn func(T) error) {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	p.validate = fn
+}
+
+// SetMaxLifetime 设置资源从创建开始最多可以使用多久，过期的资源不再交给 Acquire，0 表示不限制
+func (p *Pool[T]) SetMaxLifetime(d time.Duration) {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	p.maxLifetime = d
+	p.startReaper()
+}
+
+// SetMaxIdleTime 设置资源在池里最多可以空闲多久，空闲太久的资源会被关闭，0 表示不限制
+func (p *Pool[T]) SetMaxIdleTime(d time.Duration) {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	p.maxIdleTime = d
+	p.startReaper()
+}
+
+// shrink 关闭超过 maxIdle 的空闲资源，调用时必须持有锁
+func (p *Pool[T]) shrink() {
+	for len(p.idle) > p.maxIdle {
+		idle := p.idle[0]
+		p.idle = p.idle[1:]
+		p.closedFull++
+		p.closeLocked(idle.r)
+	}
+}
+
+// Acquire 从池中获取一个资源，资源总数达到上限时等待有资源被释放，直到 ctx 被取消
+func (p *Pool[T]) Acquire(ctx context.Context) (T, error) {
+	for {
+		r, err := p.acquire(ctx)
+		if err != nil {
+			var zero T
+			return zero, err
+		}
+
+		// 空闲的资源过期或者检查失败时关闭它，再取下一个。新创建的和刚刚被放回的资源不需要再检查
+		if !r.checked {
+			if err := p.check(r.r); err != nil {
+				p.log("Acquire:", "Stale Resource,", err)
+				p.destroy(r.r)
+				continue
+			}
+			p.log("Acquire:", "Shared Resource")
+		}
+
+		if err := p.handOut(r.r); err != nil {
+			var zero T
+			return zero, err
+		}
+		return r.r, nil
+	}
+}
+
+// handOut 记录交给调用者的资源。创建或者检查资源期间池被关闭时关闭这个资源，返回 ErrPoolClosed
+func (p *Pool[T]) handOut(r T) error {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	if p.closed {
+		p.log("Acquire:", "Pool Closed, Closing")
+		p.closeLocked(r)
+		return ErrPoolClosed
+	}
+
+	p.inUse[r] = struct{}{}
+	return nil
+}
+
+// takeBack 取消对一个资源的记录，资源不是池交出去的，或者已经被 Close 强制关闭时返回 false
+func (p *Pool[T]) takeBack(r T) bool {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	if _, ok := p.inUse[r]; !ok {
+		return false
+	}
+	delete(p.inUse, r)
+	return true
+}
+
+// acquired 是 acquire 取得的资源，checked 表示它不需要再检查
+type acquired[T Resource] struct {
+	r       T
+	checked bool
+}
+
+// acquire 取出一个空闲资源、创建一个新资源，或者排队等待别人释放资源
+func (p *Pool[T]) acquire(ctx context.Context) (*acquired[T], error) {
+	p.m.Lock()
+
+	if p.closed {
+		p.m.Unlock()
+		return nil, ErrPoolClosed
+	}
+
+	// 检查是否有空闲的资源，优先使用最近放回的资源，让长时间不用的资源过期
+	if n := len(p.idle); n > 0 {
+		idle := p.idle[n-1]
+		p.idle = p.idle[:n-1]
+		p.refill()
+		p.m.Unlock()
+
+		return &acquired[T]{r: idle.r}, nil
+	}
+
+	// 因为没有空闲资源可用，而且还没有达到上限，所以提供一个新资源
+	if p.maxOpen <= 0 || p.numOpen < p.maxOpen {
+		p.numOpen++
+		p.m.Unlock()
+
+		return p.create(ctx)
+	}
+
+	// 资源总数已经达到上限，排队等待 Release 把资源交给我们
+	wait := make(chan grant[T], 1)
+	p.waiters = append(p.waiters, wait)
+	p.waitCount++
+	p.m.Unlock()
+
+	start := time.Now()
+	defer func() {
+		p.m.Lock()
+		p.waitDuration += time.Since(start)
+		p.m.Unlock()
+	}()
+
 	select {
-	// 检查是否有空闲的资源
-	case r, ok := <-p.resources:
-		log.Println("Acquire:", "Shared Resource")
+	case g, ok := <-wait:
 		if !ok {
 			return nil, ErrPoolClosed
 		}
-		return r, nil
+		if g.create {
+			return p.create(ctx)
+		}
+		return &acquired[T]{r: g.r, checked: true}, nil
+
+	case <-ctx.Done():
+		p.m.Lock()
+		removed := p.removeWaiter(wait)
+		p.m.Unlock()
+
+		// 在取消的同时已经有资源交给了我们，把它还回去
+		if !removed {
+			if g, ok := <-wait; ok {
+				if g.create {
+					p.discard()
+				} else {
+					p.put(g.r)
+				}
+			}
+		}
+		return nil, ctx.Err()
+	}
+}
+
+// create 为 Acquire 创建资源，调用前 numOpen 已经为这个资源加一，创建失败时让出这个名额
+func (p *Pool[T]) create(ctx context.Context) (*acquired[T], error) {
+	p.log("Acquire:", "New Resource")
+
+	r, err := p.newResource(ctx)
+	if err != nil {
+		p.discard()
+		return nil, err
+	}
+
+	return &acquired[T]{r: r, checked: true}, nil
+}
+
+// check 检查资源是否超过了最长使用时间，以及是否还能使用
+func (p *Pool[T]) check(r T) error {
+	p.m.Lock()
+	validate := p.validate
+	expired := p.maxLifetime > 0 && time.Since(p.created[r]) > p.maxLifetime
+	p.m.Unlock()
+
+	if expired {
+		return errors.New("max lifetime exceeded")
+	}
+	if validate != nil {
+		return validate(r)
+	}
+	return nil
+}
+
+// destroy 关闭一个不能再使用的资源，并让出它的名额
+func (p *Pool[T]) destroy(r T) {
+	r.Close()
+
+	p.m.Lock()
+	delete(p.created, r)
+	p.closedStale++
+	p.refill()
+	p.m.Unlock()
+
+	p.discard()
+}
+
+// closeLocked 关闭一个资源并减少资源数量，调用时必须持有锁
+func (p *Pool[T]) closeLocked(r T) {
+	r.Close()
+	delete(p.created, r)
+	p.numOpen--
+	p.checkDrained()
+}
+
+// checkDrained 在池关闭后所有资源都已经关闭时通知 Close，调用时必须持有锁
+func (p *Pool[T]) checkDrained() {
+	if p.closed && p.numOpen == 0 && !p.drainClosed {
+		p.drainClosed = true
+		close(p.drained)
+	}
+}
+
+// discard 让出一个资源的名额：有人在排队时让他创建新资源，否则减少资源数量
+func (p *Pool[T]) discard() {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	if !p.closed && len(p.waiters) > 0 {
+		p.popWaiter() <- grant[T]{create: true}
+		return
+	}
+	p.numOpen--
+	p.checkDrained()
+}
+
+// popWaiter 取出排在最前面的等待者，调用时必须持有锁
+func (p *Pool[T]) popWaiter() chan grant[T] {
+	wait := p.waiters[0]
+	p.waiters = p.waiters[1:]
+	return wait
+}
+
+// removeWaiter 从队列里移除一个等待者，等待者已经被取出时返回 false，调用时必须持有锁
+func (p *Pool[T]) removeWaiter(wait chan grant[T]) bool {
+	for i, w := range p.waiters {
+		if w == wait {
+			p.waiters = append(p.waiters[:i], p.waiters[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
 
-	// 因为没有空闲资源可用，所以提供一个新资源
-	default:
-		log.Println("Acquire:", "New Resource")
-		return p.factory()
+// Discard 关闭一个已经坏掉的资源，而不是把它放回池里，它的名额可以用来创建新资源
+func (p *Pool[T]) Discard(r T) {
+	if !p.takeBack(r) {
+		p.log("Discard:", "Unknown Resource")
+		return
 	}
+
+	p.log("Discard:", "Closing")
+	p.destroy(r)
 }
 
 // Release 将一个使用后的资源放回池里
-func (p *Pool) Release(r io.Closer) {
+func (p *Pool[T]) Release(r T) {
+	// 不是池交出去的资源，或者已经被 Close 强制关闭的资源，不能再放回池里
+	if !p.takeBack(r) {
+		p.log("Release:", "Unknown Resource")
+		return
+	}
+
+	// 过期或者不能再使用的资源直接关闭
+	if err := p.check(r); err != nil {
+		p.log("Release:", "Stale Resource, Closing,", err)
+		p.destroy(r)
+		return
+	}
+
+	p.put(r)
+}
+
+// put 把一个可以使用的资源交给排队的 Acquire，或者放进空闲队列
+func (p *Pool[T]) put(r T) {
 	// 保证本操作和 Close 操作的安全
 	p.m.Lock()
 	defer p.m.Unlock()
 
 	// 如果池已经被关闭，销毁这个资源
 	if p.closed {
-		r.Close()
+		p.closeLocked(r)
+		return
+	}
+
+	// 有人在排队时直接把资源交给他
+	if len(p.waiters) > 0 {
+		p.log("Release:", "Handed Over")
+		p.popWaiter() <- grant[T]{r: r}
 		return
 	}
 
-	select {
 	// 试图将这个资源放入队列
-	case p.resources <- r:
-		log.Println("Release:", "In Queue")
+	if len(p.idle) < p.maxIdle {
+		p.log("Release:", "In Queue")
+		p.idle = append(p.idle, idleResource[T]{r: r, since: time.Now()})
+		return
+	}
 
 	// 如果队列已满，则关闭这个资源
-	default:
-		log.Println("Release:", "Queue Full, Closing")
-		r.Close()
+	p.log("Release:", "Queue Full, Closing")
+	p.closedFull++
+	p.closeLocked(r)
+}
+
+// startReaper 在设置了过期时间后启动清理过期资源的 goroutine，调用时必须持有锁
+func (p *Pool[T]) startReaper() {
+	if p.reaping || p.closed || p.reapInterval() <= 0 {
+		return
 	}
+
+	p.reaping = true
+	go p.reaper()
 }
 
-// Close 会让资源池停止工作，并关闭所有现有的资源
-func (p *Pool) Close() {
+// reapInterval 返回清理过期资源的间隔，也就是两个过期时间里较短的那个，调用时必须持有锁
+func (p *Pool[T]) reapInterval() time.Duration {
+	d := p.maxLifetime
+	if p.maxIdleTime > 0 && (d <= 0 || p.maxIdleTime < d) {
+		d = p.maxIdleTime
+	}
+	return d
+}
+
+// reaper 定期关闭过期和空闲太久的资源，直到池被关闭或者不再设置过期时间
+func (p *Pool[T]) reaper() {
+	for {
+		p.m.Lock()
+		d := p.reapInterval()
+		if d <= 0 {
+			p.reaping = false
+			p.m.Unlock()
+			return
+		}
+		p.m.Unlock()
+
+		timer := time.NewTimer(d)
+		select {
+		case <-timer.C:
+			p.reap()
+		case <-p.stop:
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// reap 关闭所有过期和空闲太久的空闲资源
+func (p *Pool[T]) reap() {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	// 空闲太久的资源最多关闭到只剩 minIdle 个，过期的资源总是关闭
+	now := time.Now()
+	idle := p.idle[:0]
+	spare := len(p.idle) - p.minIdle
+	for _, i := range p.idle {
+		expired := p.maxLifetime > 0 && now.Sub(p.created[i.r]) > p.maxLifetime
+		tooIdle := p.maxIdleTime > 0 && now.Sub(i.since) > p.maxIdleTime && spare > 0
+		if expired || tooIdle {
+			spare--
+			p.log("Reap:", "Closing Stale Resource")
+			p.closedStale++
+			p.closeLocked(i.r)
+			continue
+		}
+		idle = append(idle, i)
+	}
+
+	// 清除切片尾部的引用，让被关闭的资源可以被回收
+	for i := len(idle); i < len(p.idle); i++ {
+		p.idle[i] = idleResource[T]{}
+	}
+	p.idle = idle
+	p.refill()
+}
+
+/*
+Close 让池停止工作，等待正在使用的资源被放回后关闭所有资源
+
+Close 开始后 Acquire 都会返回 ErrPoolClosed，正在排队的 Acquire 也会马上返回，空闲的资源马上关闭。
+正在使用的资源被放回时关闭。ctx 被取消时不再等待，强制关闭还没有放回的资源并返回 ctx.Err()，
+之后再调用 Release 或者 Discard 放回这些资源时什么也不做。
+*/
+func (p *Pool[T]) Close(ctx context.Context) error {
+	p.shutdown()
+
+	select {
+	case <-p.drained:
+		return nil
+	case <-ctx.Done():
+	}
+
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	for r := range p.inUse {
+		p.log("Close:", "Still In Use, Closing")
+		delete(p.inUse, r)
+		p.closedForced++
+		p.closeLocked(r)
+	}
+	return ctx.Err()
+}
+
+// shutdown 将池关闭，通知正在排队的 Acquire，并关闭所有空闲的资源
+func (p *Pool[T]) shutdown() {
 	// 保证本操作与 Release 操作的安全
 	p.m.Lock()
 	defer p.m.Unlock()
@@ -88,14 +655,20 @@ func (p *Pool) Close() {
 		return
 	}
 
-	// 将池关闭
+	// 将池关闭，停止清理过期资源
 	p.closed = true
+	close(p.stop)
 
-	// 在清空通道里的资源之前，将通道关闭，如果不这样做，会发生死锁
-	close(p.resources)
+	// 通知所有正在排队的 Acquire
+	for _, wait := range p.waiters {
+		close(wait)
+	}
+	p.waiters = nil
 
 	// 关闭资源
-	for r := range p.resources {
-		r.Close()
+	for _, idle := range p.idle {
+		p.closeLocked(idle.r)
 	}
+	p.idle = nil
+	p.checkDrained()
 }
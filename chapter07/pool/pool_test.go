@@ -0,0 +1,533 @@
+// 这个示例程序测试资源池在并发的 Acquire、Release 和 Close 下的行为，需要使用 -race 运行
+package pool_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"notes.goinaction/chapter07/pool"
+)
+
+const checkMark = "\u2713"
+const ballotX = "\u2717"
+
+// resource 是测试用的资源，记录自己被关闭的次数
+type resource struct {
+	closes int32
+}
+
+// Close 实现 io.Closer 接口
+func (r *resource) Close() error {
+	atomic.AddInt32(&r.closes, 1)
+	return nil
+}
+
+// factory 返回一个创建资源的工厂函数，所有创建的资源都会记录在 all 里
+func factory(m *sync.Mutex, all *[]*resource) func() (*resource, error) {
+	return func() (*resource, error) {
+		r := new(resource)
+		m.Lock()
+		*all = append(*all, r)
+		m.Unlock()
+		return r, nil
+	}
+}
+
+// TestCloseWhileAcquiring 确认 Close 之后不会再交出资源，并且每个资源正好被关闭一次
+func TestCloseWhileAcquiring(t *testing.T) {
+	t.Log("Given the need to close a pool while goroutines keep acquiring resources.")
+	{
+		var (
+			m   sync.Mutex
+			all []*resource
+		)
+		p, err := pool.New(factory(&m, &all), 2)
+		if err != nil {
+			t.Fatal("\tShould create the pool.", ballotX, err)
+		}
+		p.SetMaxOpen(4)
+
+		var (
+			wg     sync.WaitGroup
+			closed atomic.Bool
+			late   atomic.Int32
+		)
+		for i := 0; i < 16; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					r, err := p.Acquire(context.Background())
+					if err != nil {
+						return
+					}
+					if closed.Load() {
+						late.Add(1)
+					}
+					time.Sleep(time.Millisecond)
+					p.Release(r)
+				}
+			}()
+		}
+
+		time.Sleep(20 * time.Millisecond)
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		err = p.Close(ctx)
+		closed.Store(true)
+		wg.Wait()
+
+		if err == nil {
+			t.Log("\tShould wait for all resources to be released.", checkMark)
+		} else {
+			t.Fatal("\tShould wait for all resources to be released.", ballotX, err)
+		}
+
+		if n := late.Load(); n == 0 {
+			t.Log("\tShould not hand out resources after Close returns.", checkMark)
+		} else {
+			t.Error("\tShould not hand out resources after Close returns.", ballotX, n)
+		}
+
+		m.Lock()
+		defer m.Unlock()
+		for i, r := range all {
+			if n := atomic.LoadInt32(&r.closes); n != 1 {
+				t.Fatalf("\tShould close every resource exactly once. %v resource #%d closed %d times", ballotX, i, n)
+			}
+		}
+		t.Logf("\tShould close every resource exactly once. %v (%d resources)", checkMark, len(all))
+
+		if s := p.Stats(); s.Open == 0 {
+			t.Log("\tShould leave no open resources.", checkMark)
+		} else {
+			t.Error("\tShould leave no open resources.", ballotX, s.Open)
+		}
+	}
+}
+
+// TestCloseDrain 确认 Close 会等待正在使用的资源被放回，超时后强制关闭它们
+func TestCloseDrain(t *testing.T) {
+	t.Log("Given the need to drain resources that are still in use.")
+	{
+		var (
+			m   sync.Mutex
+			all []*resource
+		)
+		p, _ := pool.New(factory(&m, &all), 2)
+
+		r1, _ := p.Acquire(context.Background())
+		r2, _ := p.Acquire(context.Background())
+
+		done := make(chan error, 1)
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+			done <- p.Close(ctx)
+		}()
+
+		// 等 Close 开始之后再放回第一个资源
+		time.Sleep(20 * time.Millisecond)
+		if _, err := p.Acquire(context.Background()); errors.Is(err, pool.ErrPoolClosed) {
+			t.Log("\tShould reject Acquire while draining.", checkMark)
+		} else {
+			t.Error("\tShould reject Acquire while draining.", ballotX, err)
+		}
+
+		p.Release(r1)
+		if n := atomic.LoadInt32(&r1.closes); n == 1 {
+			t.Log("\tShould close a resource released while draining.", checkMark)
+		} else {
+			t.Error("\tShould close a resource released while draining.", ballotX, n)
+		}
+
+		if err := <-done; errors.Is(err, context.DeadlineExceeded) {
+			t.Log("\tShould give up waiting at the deadline.", checkMark)
+		} else {
+			t.Fatal("\tShould give up waiting at the deadline.", ballotX, err)
+		}
+
+		if n := atomic.LoadInt32(&r2.closes); n == 1 && p.Stats().ClosedForced == 1 {
+			t.Log("\tShould force close the resource still in use.", checkMark)
+		} else {
+			t.Error("\tShould force close the resource still in use.", ballotX, n)
+		}
+
+		// 强制关闭的资源被放回时不能再关闭一次
+		p.Release(r2)
+		if n := atomic.LoadInt32(&r2.closes); n == 1 && p.Stats().Open == 0 {
+			t.Log("\tShould ignore a forced closed resource that is released later.", checkMark)
+		} else {
+			t.Error("\tShould ignore a forced closed resource that is released later.", ballotX, n)
+		}
+	}
+}
+
+// TestAcquireDeadline 确认资源总数达到上限时 Acquire 最多等到 ctx 的截止时间，之后放回的资源仍然可以取得
+func TestAcquireDeadline(t *testing.T) {
+	t.Log("Given the need to stop waiting for a resource at a deadline.")
+	{
+		var (
+			m   sync.Mutex
+			all []*resource
+		)
+		p, _ := pool.New(factory(&m, &all), 1)
+		p.SetMaxOpen(1)
+
+		r1, err := p.Acquire(context.Background())
+		if err != nil {
+			t.Fatal("\tShould acquire the only resource.", ballotX, err)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		if _, err := p.Acquire(ctx); errors.Is(err, context.DeadlineExceeded) {
+			t.Log("\tShould give up waiting at the deadline.", checkMark)
+		} else {
+			t.Fatal("\tShould give up waiting at the deadline.", ballotX, err)
+		}
+
+		if s := p.Stats(); s.WaitCount == 1 && s.WaitDuration >= 20*time.Millisecond && s.Open == 1 {
+			t.Log("\tShould count the wait without creating a resource.", checkMark)
+		} else {
+			t.Error("\tShould count the wait without creating a resource.", ballotX, s)
+		}
+
+		// 超时的 Acquire 已经离开队列，放回的资源进入空闲队列，而不是交给它
+		p.Release(r1)
+		ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		if r, err := p.Acquire(ctx); err == nil && r == r1 {
+			t.Log("\tShould acquire the released resource.", checkMark)
+		} else {
+			t.Error("\tShould acquire the released resource.", ballotX, err)
+		}
+	}
+}
+
+// closers 是不能作为 map 键的资源，第一个元素记录被关闭的次数
+type closers []int32
+
+// Close 实现 io.Closer 接口
+func (c closers) Close() error {
+	atomic.AddInt32(&c[0], 1)
+	return nil
+}
+
+// TestUnhashable 确认工厂函数返回不能作为 map 键的资源时 Acquire 返回错误，而不是 panic
+func TestUnhashable(t *testing.T) {
+	t.Log("Given the need to reject resources the pool cannot track.")
+	{
+		c := make(closers, 1)
+		p, _ := pool.New(func() (io.Closer, error) { return c, nil }, 1)
+
+		if _, err := p.Acquire(context.Background()); errors.Is(err, pool.ErrUnhashable) {
+			t.Log("\tShould return ErrUnhashable.", checkMark)
+		} else {
+			t.Fatal("\tShould return ErrUnhashable.", ballotX, err)
+		}
+
+		if n := atomic.LoadInt32(&c[0]); n == 1 && p.Stats().Open == 0 {
+			t.Log("\tShould close the rejected resource.", checkMark)
+		} else {
+			t.Error("\tShould close the rejected resource.", ballotX, n)
+		}
+	}
+}
+
+// TestStats 确认统计数据区分正在创建、空闲和正在使用的资源
+func TestStats(t *testing.T) {
+	t.Log("Given the need to report how the pool is used.")
+	{
+		var (
+			m   sync.Mutex
+			all []*resource
+		)
+		create := factory(&m, &all)
+		calls := make(chan struct{})
+		release := make(chan struct{})
+		p, _ := pool.New(func() (*resource, error) {
+			calls <- struct{}{}
+			<-release
+			return create()
+		}, 1)
+
+		acquired := make(chan *resource)
+		go func() {
+			r, _ := p.Acquire(context.Background())
+			acquired <- r
+		}()
+
+		<-calls
+		if s := p.Stats(); s.Open == 1 && s.InUse == 0 {
+			t.Log("\tShould not count a resource being created as in use.", checkMark)
+		} else {
+			t.Error("\tShould not count a resource being created as in use.", ballotX, s)
+		}
+		close(release)
+		r1 := <-acquired
+
+		go func() { <-calls }()
+		r2, _ := p.Acquire(context.Background())
+		if s := p.Stats(); s.Open == 2 && s.InUse == 2 && s.Idle == 0 {
+			t.Log("\tShould count the acquired resources.", checkMark)
+		} else {
+			t.Error("\tShould count the acquired resources.", ballotX, s)
+		}
+
+		// 最多保留一个空闲资源，第二个放回的资源会被关闭
+		p.Release(r1)
+		p.Release(r2)
+		if s := p.Stats(); s.Open == 1 && s.InUse == 0 && s.Idle == 1 && s.ClosedFull == 1 {
+			t.Log("\tShould count the resources closed by a full queue.", checkMark)
+		} else {
+			t.Error("\tShould count the resources closed by a full queue.", ballotX, s)
+		}
+	}
+}
+
+// TestDiscard 确认被丢弃的资源会被关闭，它的名额交给排队的 Acquire 创建新资源
+func TestDiscard(t *testing.T) {
+	t.Log("Given the need to replace a broken resource while others are waiting.")
+	{
+		var (
+			m   sync.Mutex
+			all []*resource
+		)
+		p, _ := pool.New(factory(&m, &all), 1)
+		p.SetMaxOpen(1)
+
+		r1, _ := p.Acquire(context.Background())
+
+		acquired := make(chan *resource)
+		go func() {
+			r, _ := p.Acquire(context.Background())
+			acquired <- r
+		}()
+
+		// 等待第二个 Acquire 开始排队
+		for p.Stats().WaitCount == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		p.Discard(r1)
+
+		select {
+		case r2 := <-acquired:
+			if r2 != nil && r2 != r1 {
+				t.Log("\tShould let the waiter create a new resource.", checkMark)
+			} else {
+				t.Error("\tShould let the waiter create a new resource.", ballotX)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("\tShould let the waiter create a new resource.", ballotX)
+		}
+
+		if n := atomic.LoadInt32(&r1.closes); n == 1 {
+			t.Log("\tShould close the discarded resource.", checkMark)
+		} else {
+			t.Error("\tShould close the discarded resource.", ballotX, n)
+		}
+
+		if s := p.Stats(); s.Open == 1 && s.InUse == 1 && s.ClosedStale == 1 {
+			t.Log("\tShould stay within the open limit.", checkMark)
+		} else {
+			t.Error("\tShould stay within the open limit.", ballotX, s)
+		}
+	}
+}
+
+// counting 返回一个创建资源的工厂函数，前 failures 次调用返回错误，calls 记录调用的次数
+func counting(calls *int32, failures int32) func() (*resource, error) {
+	return func() (*resource, error) {
+		if atomic.AddInt32(calls, 1) <= failures {
+			return nil, errors.New("factory failed")
+		}
+		return new(resource), nil
+	}
+}
+
+// TestMinIdle 确认减小空闲资源的最大数量之后，后台补充资源不会反复创建放不进空闲队列的资源
+func TestMinIdle(t *testing.T) {
+	t.Log("Given the need to keep idle resources topped up.")
+	{
+		var calls int32
+		p, _ := pool.New(counting(&calls, 0), 2)
+		defer p.Close(context.Background())
+
+		p.SetMinIdle(2)
+		if err := p.WarmUp(context.Background()); err == nil && p.Stats().Idle == 2 {
+			t.Log("\tShould create the minimum idle resources.", checkMark)
+		} else {
+			t.Fatal("\tShould create the minimum idle resources.", ballotX, err, p.Stats())
+		}
+
+		p.SetMaxIdle(1)
+		r, _ := p.Acquire(context.Background())
+		p.Release(r)
+		time.Sleep(50 * time.Millisecond)
+
+		if n := atomic.LoadInt32(&calls); n <= 3 {
+			t.Logf("\tShould not keep creating resources above the idle limit. %v %d calls", checkMark, n)
+		} else {
+			t.Errorf("\tShould not keep creating resources above the idle limit. %v %d calls", ballotX, n)
+		}
+
+		if s := p.Stats(); s.Idle == 1 {
+			t.Log("\tShould keep one idle resource.", checkMark)
+		} else {
+			t.Error("\tShould keep one idle resource.", ballotX, s)
+		}
+	}
+}
+
+// TestReapMinIdle 确认清理空闲太久的资源时至少保留 minIdle 个
+func TestReapMinIdle(t *testing.T) {
+	t.Log("Given the need to close resources that have been idle too long.")
+	{
+		var calls int32
+		p, _ := pool.New(counting(&calls, 0), 3)
+		defer p.Close(context.Background())
+
+		var rs []*resource
+		for i := 0; i < 3; i++ {
+			r, _ := p.Acquire(context.Background())
+			rs = append(rs, r)
+		}
+		for _, r := range rs {
+			p.Release(r)
+		}
+
+		p.SetMinIdle(1)
+		p.SetMaxIdleTime(10 * time.Millisecond)
+		time.Sleep(100 * time.Millisecond)
+
+		if s := p.Stats(); s.Idle == 1 && s.ClosedStale == 2 {
+			t.Log("\tShould keep the minimum idle resources.", checkMark)
+		} else {
+			t.Error("\tShould keep the minimum idle resources.", ballotX, s)
+		}
+
+		if n := atomic.LoadInt32(&calls); n == 3 {
+			t.Log("\tShould not create resources to replace the reaped ones.", checkMark)
+		} else {
+			t.Error("\tShould not create resources to replace the reaped ones.", ballotX, n)
+		}
+	}
+}
+
+// TestFactoryRetry 确认工厂函数失败后按照设置重试，每次失败都计入统计数据
+func TestFactoryRetry(t *testing.T) {
+	t.Log("Given the need to retry a failing factory.")
+	{
+		var calls int32
+		p, _ := pool.New(counting(&calls, 2), 1)
+		p.SetFactoryRetry(2, time.Millisecond)
+
+		if _, err := p.Acquire(context.Background()); err == nil && atomic.LoadInt32(&calls) == 3 {
+			t.Log("\tShould succeed after two retries.", checkMark)
+		} else {
+			t.Error("\tShould succeed after two retries.", ballotX, err, atomic.LoadInt32(&calls))
+		}
+
+		if s := p.Stats(); s.FactoryErrors == 2 && s.Open == 1 {
+			t.Log("\tShould count every failed attempt.", checkMark)
+		} else {
+			t.Error("\tShould count every failed attempt.", ballotX, s)
+		}
+
+		calls = 0
+		p, _ = pool.New(counting(&calls, 10), 1)
+		p.SetFactoryRetry(2, time.Millisecond)
+
+		if _, err := p.Acquire(context.Background()); err != nil && atomic.LoadInt32(&calls) == 3 {
+			t.Log("\tShould give up after the last retry.", checkMark)
+		} else {
+			t.Error("\tShould give up after the last retry.", ballotX, err, atomic.LoadInt32(&calls))
+		}
+
+		if s := p.Stats(); s.FactoryErrors == 3 && s.Open == 0 {
+			t.Log("\tShould release the slot of the failed resource.", checkMark)
+		} else {
+			t.Error("\tShould release the slot of the failed resource.", ballotX, s)
+		}
+	}
+}
+
+// TestValidator 确认检查失败的资源在 Release 和 Acquire 时都会被关闭，并且会创建新的资源代替它
+func TestValidator(t *testing.T) {
+	t.Log("Given the need to close resources that fail the health check.")
+	{
+		var (
+			calls  int32
+			broken atomic.Pointer[resource]
+		)
+		p, _ := pool.New(counting(&calls, 0), 1)
+		p.SetValidator(func(r *resource) error {
+			if broken.Load() == r {
+				return errors.New("broken")
+			}
+			return nil
+		})
+
+		r1, _ := p.Acquire(context.Background())
+		broken.Store(r1)
+		p.Release(r1)
+		if s := p.Stats(); atomic.LoadInt32(&r1.closes) == 1 && s.ClosedStale == 1 && s.Idle == 0 && s.Open == 0 {
+			t.Log("\tShould close a resource that fails the check on Release.", checkMark)
+		} else {
+			t.Error("\tShould close a resource that fails the check on Release.", ballotX, s)
+		}
+
+		r2, _ := p.Acquire(context.Background())
+		if r2 != r1 && atomic.LoadInt32(&calls) == 2 {
+			t.Log("\tShould create a replacement.", checkMark)
+		} else {
+			t.Error("\tShould create a replacement.", ballotX, atomic.LoadInt32(&calls))
+		}
+
+		// 放回时检查通过，之后坏掉的空闲资源在 Acquire 时被发现
+		p.Release(r2)
+		broken.Store(r2)
+		r3, _ := p.Acquire(context.Background())
+		if s := p.Stats(); r3 != r2 && atomic.LoadInt32(&r2.closes) == 1 && s.ClosedStale == 2 && s.Open == 1 {
+			t.Log("\tShould close an idle resource that fails the check on Acquire.", checkMark)
+		} else {
+			t.Error("\tShould close an idle resource that fails the check on Acquire.", ballotX, s)
+		}
+	}
+}
+
+// TestMaxLifetime 确认超过最长使用时间的资源不会被 Acquire 交出去，并且会被后台清理
+func TestMaxLifetime(t *testing.T) {
+	t.Log("Given the need to expire old resources.")
+	{
+		var calls int32
+		p, _ := pool.New(counting(&calls, 0), 1)
+		p.SetMaxLifetime(30 * time.Millisecond)
+		defer p.Close(context.Background())
+
+		r1, _ := p.Acquire(context.Background())
+		p.Release(r1)
+		time.Sleep(40 * time.Millisecond)
+
+		r2, _ := p.Acquire(context.Background())
+		if r2 != r1 && atomic.LoadInt32(&r1.closes) == 1 {
+			t.Log("\tShould not hand out an expired resource.", checkMark)
+		} else {
+			t.Error("\tShould not hand out an expired resource.", ballotX, atomic.LoadInt32(&r1.closes))
+		}
+
+		p.Release(r2)
+		time.Sleep(100 * time.Millisecond)
+		if s := p.Stats(); atomic.LoadInt32(&r2.closes) == 1 && s.Idle == 0 && s.Open == 0 && s.ClosedStale == 2 {
+			t.Log("\tShould reap an expired idle resource.", checkMark)
+		} else {
+			t.Error("\tShould reap an expired idle resource.", ballotX, s)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package pool
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// maxFillBackoff 是后台补充空闲资源连续失败时，两次尝试之间最长的等待时间
+const maxFillBackoff = 30 * time.Second
+
+/*
+SetMinIdle 设置池里至少保留的空闲资源数量，通常在创建池之后马上调用
+
+设置之后会在后台预先创建资源，并且在空闲资源被取走、过期或者关闭后补充到 n 个，需要等待资源创建完成
+可以调用 WarmUp。n 不会超过空闲资源的最大数量，资源总数也不会超过 maxOpen。
+*/
+func (p *Pool[T]) SetMinIdle(n int) {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	p.minIdle = n
+	p.clampMinIdle()
+
+	if p.minIdle > 0 && !p.filler && !p.closed {
+		p.filler = true
+		go p.fillLoop()
+	}
+	p.refill()
+}
+
+// SetFactoryRetry 让失败的工厂函数最多重试 n 次，第一次重试前等待 backoff，之后每次等待的时间翻倍
+func (p *Pool[T]) SetFactoryRetry(n int, backoff time.Duration) {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	p.retries = n
+	p.backoff = backoff
+}
+
+// WarmUp 创建资源直到空闲资源达到 minIdle 个，创建失败或者 ctx 被取消时返回错误
+func (p *Pool[T]) WarmUp(ctx context.Context) error {
+	for {
+		ok, err := p.fillOne(ctx)
+		if err != nil || !ok {
+			return err
+		}
+	}
+}
+
+// clampMinIdle 让 minIdle 不超过 maxIdle，否则补充的资源放不进空闲队列，会被马上关闭再重新创建，调用时必须持有锁
+func (p *Pool[T]) clampMinIdle() {
+	if p.minIdle > p.maxIdle {
+		p.minIdle = p.maxIdle
+	}
+}
+
+// refill 通知后台 goroutine 检查是否需要补充空闲资源，调用时必须持有锁
+func (p *Pool[T]) refill() {
+	if p.minIdle <= 0 {
+		return
+	}
+
+	select {
+	case p.fill <- struct{}{}:
+	default:
+	}
+}
+
+// fillLoop 在后台补充空闲资源，创建失败时等待一段时间再试，直到池被关闭
+func (p *Pool[T]) fillLoop() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-p.stop
+		cancel()
+	}()
+
+	var wait time.Duration
+	for {
+		select {
+		case <-p.fill:
+		case <-p.stop:
+			return
+		}
+
+		err := p.WarmUp(ctx)
+		if err == nil {
+			wait = 0
+			continue
+		}
+
+		// 连续失败时等待的时间翻倍，稍后再试
+		p.log("Fill:", "Factory Failed,", err)
+		wait = min(max(2*wait, 100*time.Millisecond), maxFillBackoff)
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+			p.m.Lock()
+			p.refill()
+			p.m.Unlock()
+		case <-p.stop:
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// fillOne 为空闲队列创建一个资源，空闲资源已经足够或者资源总数达到上限时返回 false
+func (p *Pool[T]) fillOne(ctx context.Context) (bool, error) {
+	p.m.Lock()
+	if p.closed || len(p.idle)+p.filling >= min(p.minIdle, p.maxIdle) || (p.maxOpen > 0 && p.numOpen >= p.maxOpen) {
+		p.m.Unlock()
+		return false, nil
+	}
+	p.numOpen++
+	p.filling++
+	p.m.Unlock()
+
+	p.log("Fill:", "New Resource")
+	r, err := p.newResource(ctx)
+
+	p.m.Lock()
+	p.filling--
+	p.m.Unlock()
+
+	if err != nil {
+		p.discard()
+		return false, err
+	}
+
+	p.put(r)
+	return true, nil
+}
+
+// newResource 调用工厂函数创建资源，失败时按照设置重试，成功时记录资源的创建时间
+func (p *Pool[T]) newResource(ctx context.Context) (T, error) {
+	p.m.Lock()
+	retries, backoff := p.retries, p.backoff
+	p.m.Unlock()
+
+	for attempt := 0; ; attempt++ {
+		r, err := p.factory()
+		if err == nil && !hashable(r) {
+			r.Close()
+			var zero T
+			return zero, fmt.Errorf("%w: %T", ErrUnhashable, r)
+		}
+		if err == nil {
+			p.m.Lock()
+			p.created[r] = time.Now()
+			p.m.Unlock()
+			return r, nil
+		}
+
+		p.m.Lock()
+		p.factoryErrors++
+		p.m.Unlock()
+
+		if attempt >= retries {
+			return r, err
+		}
+
+		// 等待一段时间后重试，等待期间被取消时直接返回
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return r, err
+		}
+		backoff *= 2
+	}
+}
+
+// hashable 报告 r 能否作为 map 的键。动态类型不能比较，或者包含不能比较的值时，使用它作为键会 panic
+func hashable(r interface{}) (ok bool) {
+	defer func() {
+		if recover() != nil {
+			ok = false
+		}
+	}()
+
+	_ = map[interface{}]struct{}{r: {}}
+	return true
+}
@@ -0,0 +1,106 @@
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// checkpoint 是保存在状态文件里的已完成的任务，键是任务的名字，没有名字的任务使用 "#ID"
+type checkpoint struct {
+	Tasks map[string]*completed `json:"tasks"`
+}
+
+// completed 是一个已经完成的任务的输出和完成时间
+type completed struct {
+	Output   json.RawMessage `json:"output,omitempty"`
+	Finished time.Time       `json:"finished"`
+}
+
+// outputKey 是 ctx 里保存任务输出的键
+type outputKey struct{}
+
+/*
+SetCheckpoint 设置状态文件，每个任务成功之后，它的输出都会写进这个文件
+
+所有任务都成功时状态文件会被删除，这样下一次执行从头开始。
+*/
+func (r *Runner) SetCheckpoint(path string) {
+	r.checkpointFile = path
+}
+
+// SetResume 设置是否从状态文件恢复，恢复时已经完成的任务不再执行，状态为 Resumed
+func (r *Runner) SetResume(resume bool) {
+	r.resume = resume
+}
+
+/*
+SetOutput 记录任务的输出，ctx 必须是 Runner 传给任务的 ctx
+
+输出会被编码成 JSON 保存在执行报告和状态文件里。任务失败或者重试时，这次执行记录的输出会被丢弃。
+*/
+func SetOutput(ctx context.Context, v interface{}) error {
+	output, ok := ctx.Value(outputKey{}).(*json.RawMessage)
+	if !ok {
+		return errors.New("runner: SetOutput called outside a task")
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	*output = data
+	return nil
+}
+
+// key 返回任务在状态文件里的键
+func (r *Runner) key(id int) string {
+	if name := r.tasks[id].name; name != "" {
+		return name
+	}
+	return fmt.Sprintf("#%d", id)
+}
+
+// loadCheckpoint 读取状态文件，没有设置恢复或者文件不存在时返回空的状态
+func (r *Runner) loadCheckpoint() (*checkpoint, error) {
+	cp := checkpoint{Tasks: make(map[string]*completed)}
+	if !r.resume || r.checkpointFile == "" {
+		return &cp, nil
+	}
+
+	data, err := os.ReadFile(r.checkpointFile)
+	if os.IsNotExist(err) {
+		return &cp, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return nil, fmt.Errorf("runner: reading checkpoint %s: %w", r.checkpointFile, err)
+	}
+	if cp.Tasks == nil {
+		cp.Tasks = make(map[string]*completed)
+	}
+
+	return &cp, nil
+}
+
+// save 把状态写进状态文件，先写临时文件再重命名，这样中途退出也不会留下不完整的文件
+func (cp *checkpoint) save(path string) error {
+	data, err := json.MarshalIndent(cp, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,131 @@
+package runner
+
+import (
+	"fmt"
+	"time"
+)
+
+// EventType 是任务生命周期里的事件类型
+type EventType int
+
+// 任务生命周期里的事件
+const (
+	// TaskStarted 表示任务开始执行，重试时每次执行都会发出这个事件
+	TaskStarted EventType = iota
+
+	// TaskFinished 表示任务执行成功
+	TaskFinished
+
+	// TaskFailed 表示任务在全部重试之后仍然返回错误
+	TaskFailed
+
+	// TaskTimedOut 表示任务因为自己的超时时间或者 Runner 的超时时间到期而结束
+	TaskTimedOut
+
+	// TaskInterrupted 表示任务因为收到信号或者调用者取消而结束
+	TaskInterrupted
+)
+
+// String 返回事件类型的名字
+func (t EventType) String() string {
+	switch t {
+	case TaskStarted:
+		return "started"
+	case TaskFinished:
+		return "finished"
+	case TaskFailed:
+		return "failed"
+	case TaskTimedOut:
+		return "timed_out"
+	case TaskInterrupted:
+		return "interrupted"
+	}
+	return fmt.Sprintf("EventType(%d)", int(t))
+}
+
+// MarshalText 让事件类型在 JSON 里以名字出现
+func (t EventType) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+// Progress 是 Runner 的整体进度
+type Progress struct {
+	// Done 是已经结束的任务数量，包括失败、取消、跳过和从状态文件恢复的任务
+	Done  int
+	Total int
+
+	// Elapsed 是从调用 Start 开始经过的时间
+	Elapsed time.Duration
+
+	// ETA 是按照已经结束的任务的平均时间估计的剩余时间，还没有任务结束时为 0
+	ETA time.Duration
+}
+
+// Percent 返回已经结束的任务占全部任务的百分比
+func (p Progress) Percent() float64 {
+	if p.Total == 0 {
+		return 100
+	}
+	return float64(p.Done) * 100 / float64(p.Total)
+}
+
+// Event 是一个任务生命周期里的事件
+type Event struct {
+	Type     EventType
+	Time     time.Time
+	ID       int
+	Name     string
+	Attempt  int
+	Duration time.Duration
+	Err      error
+	Progress Progress
+}
+
+/*
+Observer 接收 Runner 发出的事件
+
+多个任务并行执行时，事件也会按照发生的顺序依次传给 Observe，不会并发调用。
+Runner 因为超时或者信号提前返回之后，正在执行的任务结束时仍然会发出事件。
+*/
+type Observer interface {
+	Observe(e Event)
+}
+
+// ObserverFunc 让普通的函数可以作为 Observer 使用
+type ObserverFunc func(e Event)
+
+// Observe 调用 f(e)
+func (f ObserverFunc) Observe(e Event) {
+	f(e)
+}
+
+// AddObserver 添加接收事件的 Observer
+func (r *Runner) AddObserver(observers ...Observer) {
+	r.observers = append(r.observers, observers...)
+}
+
+// emit 计算当前的进度，然后把事件依次传给每个 Observer
+func (r *Runner) emit(e Event) {
+	if len(r.observers) == 0 {
+		return
+	}
+
+	// 在同一把锁里计算进度和发出事件，这样 Observer 看到的进度不会倒退
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	e.Time = time.Now()
+	e.Progress = Progress{Total: len(r.results), Elapsed: e.Time.Sub(r.started)}
+	for _, result := range r.results {
+		if result.Status != Pending && result.Status != Running {
+			e.Progress.Done++
+		}
+	}
+	if done := e.Progress.Done; done > 0 {
+		e.Progress.ETA = e.Progress.Elapsed / time.Duration(done) * time.Duration(e.Progress.Total-done)
+	}
+
+	for _, o := range r.observers {
+		o.Observe(e)
+	}
+}
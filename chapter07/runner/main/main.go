@@ -1,28 +1,96 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"log"
 	"os"
+	"syscall"
 	"time"
 
 	"notes.goinaction/chapter07/runner"
+	"notes.goinaction/cron"
 )
 
 // timeout 规定了必须在多少秒内处理完成
 const timeout = 3 * time.Second
 
+// 命令行参数
+var (
+	checkpoint = flag.String("checkpoint", "", "记录已完成任务的状态文件")
+	resume     = flag.Bool("resume", false, "跳过状态文件里已经完成的任务")
+	progress   = flag.Bool("progress", false, "在标准错误输出显示进度条")
+	events     = flag.String("events", "", "以 JSON 格式记录任务事件的文件，- 表示标准输出")
+	schedule   = flag.String("schedule", "", "反复执行的计划，例如 \"@every 10s\" 或者 \"*/5 * * * *\"")
+	overlap    = flag.String("overlap", "skip", "上一次执行还没有结束时的处理策略：skip、queue 或者 cancel")
+	jitter     = flag.Duration("jitter", 0, "每次执行随机推迟的最长时间")
+)
+
 // main 程序入口
 func main() {
+	flag.Parse()
+
 	log.Println("Starting work.")
 
 	// 为本次执行分配超时时间
 	r := runner.New(timeout)
 
+	// 每个任务最多执行 2 秒
+	r.SetTaskTimeout(2 * time.Second)
+
+	// 收到 SIGINT 或者 SIGTERM 后给正在执行的任务 1 秒钟的时间结束，再次收到信号时强制退出
+	r.SetSignals(os.Interrupt, syscall.SIGTERM)
+	r.SetGracePeriod(time.Second)
+	r.AddCleanup(func() {
+		log.Println("Cleaning up.")
+	})
+
+	// 任务失败后继续执行后面的任务，每个失败的任务最多重试 1 次
+	r.SetPolicy(runner.ContinueOnError)
+	r.SetRetry(1, 100*time.Millisecond)
+
+	// 超时之后再次执行时从上次停止的地方继续
+	if *checkpoint != "" {
+		r.SetCheckpoint(*checkpoint)
+		r.SetResume(*resume)
+	}
+
+	// 显示进度条，记录任务事件
+	var bar *runner.ProgressBar
+	if *progress {
+		bar = runner.NewProgressBar(os.Stderr)
+		r.AddObserver(bar)
+	}
+	if *events != "" {
+		w := os.Stdout
+		if *events != "-" {
+			file, err := os.Create(*events)
+			if err != nil {
+				log.Fatalln(err)
+			}
+			defer file.Close()
+			w = file
+		}
+		r.AddObserver(runner.NewJSONLogger(w))
+	}
+
 	// 加入要执行的任务
-	r.Add(createTask(), createTask(), createTask())
+	r.AddContext(createTask(), createTask(), createTask())
+
+	// 按照计划反复执行任务
+	if *schedule != "" {
+		runScheduled(r)
+		return
+	}
 
 	// 执行任务并处理结果
-	if err := r.Start(); err != nil {
+	report, err := r.Start()
+	if bar != nil {
+		bar.Close()
+	}
+	logReport(report)
+
+	if err != nil {
 		switch err {
 		case runner.ErrTimeout:
 			log.Println("Terminating due to timeout.")
@@ -30,16 +98,67 @@ func main() {
 		case runner.ErrInterrupt:
 			log.Println("Terminating due to interrupt.")
 			os.Exit(2)
+		default:
+			log.Println("Terminating due to error:", err)
+			os.Exit(3)
 		}
 	}
 
 	log.Println("Process ended.")
 }
 
-// createTask 返回一个根据 id 休眠指定秒数的示例任务
-func createTask() func(int) {
-	return func(id int) {
+// runScheduled 按照 -schedule 参数反复执行任务，直到收到中断信号
+func runScheduled(r *runner.Runner) {
+	sched, err := cron.Parse(*schedule)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	s := runner.NewScheduler(r, sched)
+	s.SetJitter(*jitter)
+	switch *overlap {
+	case "skip":
+		s.SetOverlap(runner.Skip)
+	case "queue":
+		s.SetOverlap(runner.Queue)
+	case "cancel":
+		s.SetOverlap(runner.CancelPrevious)
+	default:
+		log.Fatalf("unknown overlap policy %q", *overlap)
+	}
+
+	s.OnRun(func(report *runner.Report, err error) {
+		logReport(report)
+		if err != nil {
+			log.Println("Run failed:", err)
+		}
+	})
+
+	// Scheduler 在两次执行之间也接收 Runner 的信号，执行期间收到信号时仍然先等待宽限时间
+	if err := s.Run(context.Background()); err != nil {
+		log.Println("Scheduler stopped:", err)
+	}
+	log.Println("Process ended.")
+}
+
+// logReport 输出每个任务的执行结果
+func logReport(report *runner.Report) {
+	for _, t := range report.Tasks {
+		log.Printf("Task #%d %v after %d attempt(s) in %v.", t.ID, t.Status, t.Attempts, t.Duration)
+	}
+}
+
+// createTask 返回一个根据 id 休眠指定秒数的示例任务，任务被取消时返回取消的原因
+func createTask() runner.Task {
+	return func(ctx context.Context, id int) error {
 		log.Printf("Processor - Task #%d.", id)
-		time.Sleep(time.Duration(id) * time.Second)
+
+		select {
+		case <-time.After(time.Duration(id) * time.Second):
+			return runner.SetOutput(ctx, id*id)
+		case <-ctx.Done():
+			log.Printf("Processor - Task #%d canceled: %v.", id, ctx.Err())
+			return ctx.Err()
+		}
 	}
 }
@@ -0,0 +1,112 @@
+package runner
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// barWidth 是进度条的宽度
+const barWidth = 30
+
+// ProgressBar 在终端里显示一个不断刷新的进度条，任务失败、超时或者被中断时在进度条上方输出一行
+type ProgressBar struct {
+	w io.Writer
+
+	// open 表示进度条所在的行还没有换行
+	open bool
+}
+
+// NewProgressBar 返回一个把进度条写到 w 的 ProgressBar，w 通常是 os.Stderr
+func NewProgressBar(w io.Writer) *ProgressBar {
+	return &ProgressBar{w: w}
+}
+
+// Observe 实现 Observer 接口
+func (b *ProgressBar) Observe(e Event) {
+	// \r 回到行首，\033[K 清除这一行剩下的内容
+	fmt.Fprint(b.w, "\r\033[K")
+
+	if e.Type != TaskStarted && e.Type != TaskFinished {
+		fmt.Fprintf(b.w, "%s %s: %v\n", taskLabel(e.ID, e.Name), e.Type, e.Err)
+	}
+
+	p := e.Progress
+	filled := barWidth * p.Done / max(p.Total, 1)
+	fmt.Fprintf(b.w, "[%s%s] %3.0f%% %d/%d",
+		strings.Repeat("#", filled), strings.Repeat(" ", barWidth-filled), p.Percent(), p.Done, p.Total)
+	if p.Done > 0 && p.Done < p.Total {
+		fmt.Fprintf(b.w, " ETA %v", p.ETA.Round(time.Second))
+	}
+
+	b.open = true
+
+	// 全部任务结束后换行，这样后面的输出不会覆盖进度条
+	if p.Done == p.Total {
+		b.Close()
+	}
+}
+
+// Close 在进度条后面换行。有任务被跳过或者取消时进度条不会到达 100%，需要在 Start 返回后调用
+func (b *ProgressBar) Close() {
+	if b.open {
+		fmt.Fprintln(b.w)
+		b.open = false
+	}
+}
+
+// JSONLogger 把每个事件写成一行 JSON，方便交给日志系统处理
+type JSONLogger struct {
+	enc *json.Encoder
+}
+
+// NewJSONLogger 返回一个把事件写到 w 的 JSONLogger
+func NewJSONLogger(w io.Writer) *JSONLogger {
+	return &JSONLogger{enc: json.NewEncoder(w)}
+}
+
+// jsonEvent 是事件在 JSON 里的格式，时间长度使用毫秒
+type jsonEvent struct {
+	Type       EventType `json:"type"`
+	Time       time.Time `json:"time"`
+	ID         int       `json:"id"`
+	Name       string    `json:"name,omitempty"`
+	Attempt    int       `json:"attempt"`
+	DurationMS int64     `json:"duration_ms,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	Done       int       `json:"done"`
+	Total      int       `json:"total"`
+	Percent    float64   `json:"percent"`
+	ETAMS      int64     `json:"eta_ms"`
+}
+
+// Observe 实现 Observer 接口，写入失败时忽略错误，不影响任务的执行
+func (l *JSONLogger) Observe(e Event) {
+	je := jsonEvent{
+		Type:       e.Type,
+		Time:       e.Time,
+		ID:         e.ID,
+		Name:       e.Name,
+		Attempt:    e.Attempt,
+		DurationMS: e.Duration.Milliseconds(),
+		Done:       e.Progress.Done,
+		Total:      e.Progress.Total,
+		Percent:    e.Progress.Percent(),
+		ETAMS:      e.Progress.ETA.Milliseconds(),
+	}
+	if e.Err != nil {
+		je.Error = e.Err.Error()
+	}
+
+	l.enc.Encode(je)
+}
+
+// taskLabel 返回任务在输出里的名字
+func taskLabel(id int, name string) string {
+	if name != "" {
+		return fmt.Sprintf("task #%d (%s)", id, name)
+	}
+	return fmt.Sprintf("task #%d", id)
+}
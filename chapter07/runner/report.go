@@ -0,0 +1,127 @@
+package runner
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Status 是一个任务的执行状态
+type Status int
+
+// 任务的执行状态
+const (
+	// Pending 表示任务还没有开始执行
+	Pending Status = iota
+
+	// Running 表示任务正在执行
+	Running
+
+	// Succeeded 表示任务执行成功
+	Succeeded
+
+	// Failed 表示任务返回了错误
+	Failed
+
+	// Canceled 表示任务因为超时、中断或者调用者取消而没有执行完
+	Canceled
+
+	// Skipped 表示任务因为前面的任务或者依赖的任务失败而没有执行
+	Skipped
+
+	// Resumed 表示任务在之前的执行里已经完成，这次从状态文件恢复了它的输出
+	Resumed
+)
+
+// String 返回状态的名字
+func (s Status) String() string {
+	switch s {
+	case Pending:
+		return "pending"
+	case Running:
+		return "running"
+	case Succeeded:
+		return "succeeded"
+	case Failed:
+		return "failed"
+	case Canceled:
+		return "canceled"
+	case Skipped:
+		return "skipped"
+	case Resumed:
+		return "resumed"
+	}
+	return fmt.Sprintf("Status(%d)", int(s))
+}
+
+// TaskResult 记录一个任务的执行结果
+type TaskResult struct {
+	ID       int
+	Name     string
+	Status   Status
+	Attempts int
+	Duration time.Duration
+	Err      error
+
+	// Output 是任务使用 SetOutput 记录的输出
+	Output json.RawMessage
+}
+
+// Report 是一次执行的报告，Tasks 按照任务 ID 的顺序排列
+type Report struct {
+	Tasks    []TaskResult
+	Duration time.Duration
+}
+
+// Failed 返回所有失败的任务
+func (r *Report) Failed() []TaskResult {
+	var failed []TaskResult
+	for _, t := range r.Tasks {
+		if t.Status == Failed {
+			failed = append(failed, t)
+		}
+	}
+
+	return failed
+}
+
+// TaskError 是某个任务返回的错误，记录了任务的 ID
+type TaskError struct {
+	ID   int
+	Name string
+	Err  error
+}
+
+// Error 实现 error 接口
+func (e *TaskError) Error() string {
+	return fmt.Sprintf("%s: %v", taskLabel(e.ID, e.Name), e.Err)
+}
+
+// Unwrap 返回任务返回的原始错误
+func (e *TaskError) Unwrap() error {
+	return e.Err
+}
+
+// Errors 是 ContinueOnError 策略下多个任务返回的错误
+type Errors []*TaskError
+
+// Error 实现 error 接口，把所有错误用分号连接起来
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+
+	return strings.Join(msgs, "; ")
+}
+
+// Unwrap 返回所有的错误，这样 errors.Is 和 errors.As 可以检查其中的任意一个
+func (e Errors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, err := range e {
+		errs[i] = err
+	}
+
+	return errs
+}
@@ -1,25 +1,95 @@
 package runner
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"os"
 	"os/signal"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 )
 
+/*
+Task 是可以被取消的任务，接收一个 context 和 int 类型的 ID，执行失败时返回错误
+
+Runner 超时、收到中断信号或者任务自己的超时时间到期时，ctx 会被取消。任务应该在 ctx.Done()
+关闭后尽快返回，这样执行任务的 goroutine 才能退出。
+*/
+type Task func(ctx context.Context, id int) error
+
+// Policy 决定任务返回错误之后 Runner 怎么处理后面的任务
+type Policy int
+
+const (
+	// FailFast 在第一个任务失败时停止执行，Start 返回这个任务的 *TaskError
+	FailFast Policy = iota
+
+	// ContinueOnError 在任务失败后继续执行后面的任务，Start 返回所有失败任务的 Errors
+	ContinueOnError
+)
+
 // Runner 在给定的超时时间内执行一组任务，并且在操作系统发送中断信号时结束这些任务
 type Runner struct {
 	// interrupt 通道报告从操作系统发送的信号
 	interrupt chan os.Signal
 
-	// complete 通道报告处理任务已经完成
-	complete chan error
+	// signals 是 Runner 监听的信号，默认只有 os.Interrupt
+	signals []os.Signal
+
+	// scheduled 表示信号由 Scheduler 在多次执行期间统一接收，StartContext 不再自己注册和停止接收
+	scheduled bool
+
+	// grace 是收到第一个信号后等待正在执行的任务结束的时间，为 0 时立即取消任务
+	grace time.Duration
+
+	// stop 在收到第一个信号时关闭，之后不再启动新的任务
+	stop chan struct{}
+
+	// checkpointFile 是记录已完成任务的状态文件，resume 表示是否跳过状态文件里已完成的任务
+	checkpointFile string
+	resume         bool
+
+	// observers 接收任务生命周期里的事件
+	observers []Observer
+
+	// cleanups 是 Start 返回前按照添加的相反顺序执行的清理函数
+	cleanups []func()
 
-	// timeout 报告处理任务已经超时
-	timeout <-chan time.Time
+	// timeout 是执行全部任务的超时时间，从调用 Start 开始计时
+	timeout time.Duration
 
-	// tasks 持有一组以索引顺序依次执行的函数
-	tasks []func(int)
+	// taskTimeout 是每个任务的超时时间，为 0 时任务只受 timeout 的限制
+	taskTimeout time.Duration
+
+	// policy 决定任务失败之后怎么处理后面的任务
+	policy Policy
+
+	// retries 是任务失败后重试的次数，backoff 是第一次重试前等待的时间，之后每次翻倍
+	retries int
+	backoff time.Duration
+
+	// parallelism 是同时执行的任务的最大数量
+	parallelism int
+
+	// done 在执行任务的 goroutine 退出时关闭
+	done chan struct{}
+
+	// tasks 持有一组任务，没有依赖关系的任务以索引顺序执行
+	tasks []*task
+
+	// names 是任务名字到任务索引的映射
+	names map[string]int
+
+	// m 保护 results，Start 可能在任务还在执行时就返回报告
+	m       sync.Mutex
+	results []TaskResult
+
+	// started 是调用 Start 的时间
+	started time.Time
 }
 
 // ErrTimeout 会在任务执行超时时返回
@@ -28,73 +98,537 @@ var ErrTimeout = errors.New("received timeout")
 // ErrInterrupt 会在接收到操作系统的事件时返回
 var ErrInterrupt = errors.New("received interrupt")
 
+// ErrCycle 会在添加的任务和已有的任务形成循环依赖时返回
+var ErrCycle = errors.New("dependency cycle")
+
+// ErrDuplicateTask 会在添加的任务和已有的任务同名时返回
+var ErrDuplicateTask = errors.New("duplicate task name")
+
+// ErrUnknownDependency 会在 Start 时发现任务依赖了一个不存在的任务时返回
+var ErrUnknownDependency = errors.New("unknown dependency")
+
+// task 是一个已注册的任务
+type task struct {
+	name string
+	fn   Task
+	deps []string
+}
+
 // New 返回一个新的准备使用的 Runner
 func New(d time.Duration) *Runner {
 	return &Runner{
 		// 缓冲区容量为 1 的通道
-		interrupt: make(chan os.Signal, 1),
-		// 无缓冲的通道
-		complete: make(chan error),
-		// 语言运行时会在指定的 duration 时间到期之后，向这个通道发送一个 time.Time 的值
-		timeout: time.After(d),
+		interrupt:   make(chan os.Signal, 1),
+		signals:     []os.Signal{os.Interrupt},
+		timeout:     d,
+		parallelism: 1,
+		names:       make(map[string]int),
 	}
 }
 
+// SetTaskTimeout 设置每个任务的超时时间，超时的任务的 ctx 会被取消，然后继续执行下一个任务
+func (r *Runner) SetTaskTimeout(d time.Duration) {
+	r.taskTimeout = d
+}
+
+/*
+SetSignals 设置 Runner 监听的信号，例如 os.Interrupt、syscall.SIGTERM 和 syscall.SIGHUP
+
+不传参数表示不监听任何信号。signal.Notify 在没有指定信号时会转发所有信号，包括运行时内部使用的 SIGURG，
+所以这时不会调用它。
+*/
+func (r *Runner) SetSignals(sigs ...os.Signal) {
+	r.signals = sigs
+}
+
+/*
+SetGracePeriod 设置收到信号后的宽限时间
+
+收到第一个信号时 Runner 不再启动新的任务，等待正在执行的任务在 d 内结束。宽限时间用完、
+再次收到信号或者整体超时时，正在执行的任务的 ctx 会被取消。d 为 0 时收到信号立即取消。
+*/
+func (r *Runner) SetGracePeriod(d time.Duration) {
+	r.grace = d
+}
+
+// AddCleanup 添加清理函数，不管执行的结果如何，Start 返回前都会按照添加的相反顺序执行这些函数
+func (r *Runner) AddCleanup(fns ...func()) {
+	r.cleanups = append(r.cleanups, fns...)
+}
+
+// SetPolicy 设置任务失败之后的处理策略，默认是 FailFast
+func (r *Runner) SetPolicy(p Policy) {
+	r.policy = p
+}
+
+/*
+SetParallelism 设置同时执行的任务的最大数量，默认是 1
+
+大于 1 时，所有依赖已经完成的任务都可能同时执行，包括使用 Add 和 AddContext 添加的没有依赖的任务。
+*/
+func (r *Runner) SetParallelism(n int) {
+	if n < 1 {
+		n = 1
+	}
+	r.parallelism = n
+}
+
+/*
+SetRetry 让失败的任务最多重试 n 次
+
+第一次重试前等待 backoff，之后每次等待的时间翻倍。重试全部失败之后才按照 Policy 处理这个任务的错误。
+*/
+func (r *Runner) SetRetry(n int, backoff time.Duration) {
+	r.retries = n
+	r.backoff = backoff
+}
+
 // Add 将一个任务附加到 Runner 上。这个任务是一个接收一个 int 类型的 ID 作为参数的函数
+//
+// 这种任务无法感知取消，也不会失败，Runner 只能在两个任务之间停止。需要响应取消或者报告错误的任务请使用 AddContext。
 func (r *Runner) Add(tasks ...func(int)) {
-	r.tasks = append(r.tasks, tasks...)
+	for _, fn := range tasks {
+		fn := fn
+		r.tasks = append(r.tasks, &task{fn: func(ctx context.Context, id int) error {
+			fn(id)
+			return nil
+		}})
+	}
+}
+
+// AddContext 将一组可以被取消、可以返回错误的任务附加到 Runner 上
+func (r *Runner) AddContext(tasks ...Task) {
+	for _, fn := range tasks {
+		r.tasks = append(r.tasks, &task{fn: fn})
+	}
+}
+
+/*
+AddTask 添加一个有名字的任务，这个任务在 deps 列出的所有任务都成功之后才会执行
+
+deps 可以引用还没有添加的任务，Start 时仍然不存在的依赖会导致 ErrUnknownDependency。
+添加的任务和已有的任务形成循环依赖时返回 ErrCycle，同名的任务已经存在时返回 ErrDuplicateTask，
+这两种情况下任务都不会被添加。
+*/
+func (r *Runner) AddTask(name string, fn Task, deps ...string) error {
+	if _, exists := r.names[name]; exists || name == "" {
+		return fmt.Errorf("%w: %q", ErrDuplicateTask, name)
+	}
+
+	// 从每个依赖出发沿着依赖关系查找，能够回到这个任务说明存在循环
+	for _, dep := range deps {
+		if path := r.pathTo(dep, name, nil); path != nil {
+			return fmt.Errorf("%w: %s -> %s", ErrCycle, name, strings.Join(path, " -> "))
+		}
+	}
+
+	r.names[name] = len(r.tasks)
+	r.tasks = append(r.tasks, &task{name: name, fn: fn, deps: deps})
+	return nil
+}
+
+// pathTo 返回从任务 from 沿着依赖关系到达任务 to 的路径，不能到达时返回 nil
+func (r *Runner) pathTo(from, to string, path []string) []string {
+	path = append(path, from)
+	if from == to {
+		return path
+	}
+
+	id, exists := r.names[from]
+	if !exists {
+		return nil
+	}
+	for _, dep := range r.tasks[id].deps {
+		if p := r.pathTo(dep, to, path); p != nil {
+			return p
+		}
+	}
+
+	return nil
 }
 
 // Start 执行所有任务，并监视通道事件
-func (r *Runner) Start() error {
-	// 我们希望接收所有中断信号
-	signal.Notify(r.interrupt, os.Interrupt)
+func (r *Runner) Start() (*Report, error) {
+	return r.StartContext(context.Background())
+}
+
+/*
+StartContext 执行所有任务，返回每个任务的执行报告。ctx 被取消时停止执行并返回 ctx.Err()
+
+超时或者收到信号时，正在执行的任务的 ctx 会被取消，StartContext 马上返回，不会再执行后面的任务。
+这时返回的报告里，正在执行的任务的状态是 Running。执行任务的 goroutine 在当前任务返回后
+才会退出，Done 返回的通道可以用来等待这个 goroutine 退出。
+
+设置了宽限时间时，收到第一个信号后不会马上取消，而是先等待正在执行的任务结束，参见 SetGracePeriod。
+*/
+func (r *Runner) StartContext(ctx context.Context) (*Report, error) {
+	// 清理函数在取消任务之后执行
+	defer r.cleanup()
+
+	// 取消的原因让任务结束时能够区分超时和中断
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	// 我们希望接收所有设置的信号，返回时停止接收
+	if len(r.signals) > 0 && !r.scheduled {
+		signal.Notify(r.interrupt, r.signals...)
+		defer signal.Stop(r.interrupt)
+	}
+
+	// 超时从调用 Start 时开始计算
+	start := time.Now()
+	timer := time.NewTimer(r.timeout)
+	defer timer.Stop()
+
+	r.m.Lock()
+	r.started = start
+	r.results = make([]TaskResult, len(r.tasks))
+	for id, t := range r.tasks {
+		r.results[id].ID = id
+		r.results[id].Name = t.name
+	}
+	r.m.Unlock()
+
+	// complete 通道报告处理任务已经完成。缓冲区容量为 1，这样即使 Start 已经因为超时返回，
+	// goroutine 也可以写入结果并退出，而不会永远阻塞在这里
+	complete := make(chan error, 1)
+	r.done = make(chan struct{})
+	r.stop = make(chan struct{})
 
 	// 用不同的 goroutine 执行不同的任务
 	go func() {
-		r.complete <- r.run()
+		defer close(r.done)
+		complete <- r.run(ctx)
 	}()
 
-	// 阻塞等待两个事件中的任意一个
+	// 阻塞等待事件中的任意一个
+	var err error
 	select {
 	// 当任务处理完成时发出的信号
-	case err := <-r.complete:
-		return err
+	case err = <-complete:
+
 	// 当任务处理程序运行超时时发出的信号
-	case <-r.timeout:
-		return ErrTimeout
+	case <-timer.C:
+		err = ErrTimeout
+		cancel(ErrTimeout)
+
+	// 当中断事件被触发时发出的信号
+	case <-r.interrupt:
+		err = ErrInterrupt
+		r.shutdown(complete, timer.C)
+		cancel(ErrInterrupt)
+
+	// 当调用者取消时发出的信号
+	case <-ctx.Done():
+		err = ctx.Err()
 	}
+
+	return r.report(time.Since(start)), err
 }
 
-// run 执行每一个已注册的任务
-func (r *Runner) run() error {
-	for id, task := range r.tasks {
-		// 检测操作系统的中断信号
-		if r.gotInterrupt() {
-			return ErrInterrupt
-		}
+/*
+shutdown 在收到第一个信号后停止启动新的任务，并等待正在执行的任务结束
 
-		// 执行已注册的任务
-		task(id)
+宽限时间用完、再次收到信号或者整体超时时直接返回，由 Start 取消正在执行的任务。
+*/
+func (r *Runner) shutdown(complete <-chan error, timeout <-chan time.Time) {
+	close(r.stop)
+	if r.grace <= 0 {
+		return
 	}
 
-	return nil
-}
+	grace := time.NewTimer(r.grace)
+	defer grace.Stop()
 
-// gotInterrupt 验证是否接收到了中断信号
-func (r *Runner) gotInterrupt() bool {
-	// 一般来说，select 语句在没有任何要接收的数据时会阻塞，不过有了 default 分支就不会阻塞了。
-	// default 分支会将接收 interrupt 通道的阻塞调用转变为非阻塞的。如果 interrupt 通道有
-	// 中断信号需要接收，就会接收并处理这个中断。如果没有需要接收的信号，就会执行 default 分支。
 	select {
-	// 当中断事件被触发时发出的信号
+	case <-complete:
+	case <-grace.C:
 	case <-r.interrupt:
-		// 停止接收后续的任何信号
-		signal.Stop(r.interrupt)
-		return true
+	case <-timeout:
+	}
+}
 
-	// 继续正常运行
+// stopping 报告是否已经收到信号，需要停止启动新的任务
+func (r *Runner) stopping() bool {
+	select {
+	case <-r.stop:
+		return true
 	default:
 		return false
 	}
 }
+
+// cleanup 按照添加的相反顺序执行清理函数
+func (r *Runner) cleanup() {
+	for i := len(r.cleanups) - 1; i >= 0; i-- {
+		r.cleanups[i]()
+	}
+}
+
+// Done 返回一个在执行任务的 goroutine 退出时关闭的通道，必须在 Start 之后调用
+func (r *Runner) Done() <-chan struct{} {
+	return r.done
+}
+
+// report 返回当前执行报告的副本
+func (r *Runner) report(d time.Duration) *Report {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	return &Report{
+		Tasks:    append([]TaskResult(nil), r.results...),
+		Duration: d,
+	}
+}
+
+// setResult 记录一个任务的执行结果
+func (r *Runner) setResult(result TaskResult) {
+	r.m.Lock()
+	r.results[result.ID] = result
+	r.m.Unlock()
+}
+
+// finish 把所有还没有开始执行的任务标记为 status
+func (r *Runner) finish(status Status) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	for id := range r.results {
+		if r.results[id].Status == Pending {
+			r.results[id].Status = status
+		}
+	}
+}
+
+// outcome 是一个任务执行结束的消息
+type outcome struct {
+	id  int
+	err error
+}
+
+/*
+run 按照依赖关系执行每一个已注册的任务
+
+依赖都已经成功的任务进入就绪队列，就绪队列按照任务索引排序，最多同时执行 parallelism 个任务。
+任务失败后依赖它的任务不会进入就绪队列，最后被标记为 Skipped。Runner 被取消或者收到信号时不再启动
+新的任务，等待正在执行的任务返回后退出。
+*/
+func (r *Runner) run(ctx context.Context) error {
+	waiting, dependents, err := r.graph()
+	if err != nil {
+		r.finish(Skipped)
+		return err
+	}
+
+	cp, err := r.loadCheckpoint()
+	if err != nil {
+		r.finish(Skipped)
+		return err
+	}
+
+	var ready []int
+	for id, n := range waiting {
+		if n == 0 {
+			ready = append(ready, id)
+		}
+	}
+
+	var (
+		errs    Errors
+		first   error
+		running int
+		done    = make(chan outcome)
+	)
+
+	// succeeded 让依赖全部完成的任务进入就绪队列
+	succeeded := func(id int) {
+		for _, d := range dependents[id] {
+			if waiting[d]--; waiting[d] == 0 {
+				ready = append(ready, d)
+			}
+		}
+		sort.Ints(ready)
+	}
+
+	for {
+		// 在没有失败、没有被取消、没有收到信号时启动就绪的任务
+		for first == nil && ctx.Err() == nil && !r.stopping() && running < r.parallelism && len(ready) > 0 {
+			id := ready[0]
+			ready = ready[1:]
+
+			// 之前已经完成的任务直接使用保存的输出
+			if c, exists := cp.Tasks[r.key(id)]; exists {
+				r.setResult(TaskResult{ID: id, Name: r.tasks[id].name, Status: Resumed, Output: c.Output})
+				r.emit(Event{Type: TaskFinished, ID: id, Name: r.tasks[id].name})
+				succeeded(id)
+				continue
+			}
+
+			running++
+
+			go func(id int) {
+				done <- outcome{id, r.runTask(ctx, id, r.tasks[id].fn)}
+			}(id)
+		}
+
+		if running == 0 {
+			break
+		}
+
+		o := <-done
+		running--
+
+		if o.err == nil {
+			// 状态文件写入失败时停止执行，否则中断之后无法恢复
+			if err := r.record(cp, o.id); err != nil {
+				first = err
+				continue
+			}
+			succeeded(o.id)
+			continue
+		}
+
+		// 任务因为 Runner 被取消而失败时，不再按照失败处理
+		if ctx.Err() != nil {
+			continue
+		}
+
+		taskErr := &TaskError{ID: o.id, Name: r.tasks[o.id].name, Err: o.err}
+		if r.policy == FailFast {
+			first = taskErr
+		}
+		errs = append(errs, taskErr)
+	}
+
+	if err := ctx.Err(); err != nil {
+		r.finish(Canceled)
+		return err
+	}
+	if r.stopping() {
+		r.finish(Canceled)
+		return ErrInterrupt
+	}
+	r.finish(Skipped)
+
+	switch {
+	case first != nil:
+		return first
+	case len(errs) > 0:
+		return errs
+	}
+
+	// 全部任务都已经完成，下一次执行从头开始
+	if r.checkpointFile != "" {
+		if err := os.Remove(r.checkpointFile); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// record 把成功的任务和它的输出写进状态文件
+func (r *Runner) record(cp *checkpoint, id int) error {
+	if r.checkpointFile == "" {
+		return nil
+	}
+
+	r.m.Lock()
+	output := r.results[id].Output
+	r.m.Unlock()
+
+	cp.Tasks[r.key(id)] = &completed{Output: output, Finished: time.Now()}
+	if err := cp.save(r.checkpointFile); err != nil {
+		return fmt.Errorf("runner: writing checkpoint: %w", err)
+	}
+	return nil
+}
+
+// graph 返回每个任务还在等待的依赖数量，以及依赖每个任务的任务列表
+func (r *Runner) graph() ([]int, [][]int, error) {
+	waiting := make([]int, len(r.tasks))
+	dependents := make([][]int, len(r.tasks))
+
+	for id, t := range r.tasks {
+		for _, dep := range t.deps {
+			d, exists := r.names[dep]
+			if !exists {
+				return nil, nil, fmt.Errorf("%w: %q required by %q", ErrUnknownDependency, dep, t.name)
+			}
+			waiting[id]++
+			dependents[d] = append(dependents[d], id)
+		}
+	}
+
+	return waiting, dependents, nil
+}
+
+// runTask 执行一个任务并记录结果，失败时按照设置重试
+func (r *Runner) runTask(ctx context.Context, id int, task Task) error {
+	result := TaskResult{ID: id, Name: r.tasks[id].name, Status: Running}
+	start := time.Now()
+	backoff := r.backoff
+
+	var (
+		err    error
+		output json.RawMessage
+	)
+	for {
+		result.Attempts++
+		r.setResult(result)
+		r.emit(Event{Type: TaskStarted, ID: id, Name: result.Name, Attempt: result.Attempts})
+		if output, err = r.attempt(ctx, id, task); err == nil || result.Attempts > r.retries {
+			break
+		}
+
+		// 等待一段时间后重试，等待期间被取消时直接返回
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		backoff *= 2
+	}
+
+	result.Duration = time.Since(start)
+	result.Err = err
+	event := Event{ID: id, Name: result.Name, Attempt: result.Attempts, Duration: result.Duration, Err: err}
+	switch {
+	case err == nil:
+		result.Status = Succeeded
+		result.Output = output
+		event.Type = TaskFinished
+	case ctx.Err() != nil:
+		result.Status = Canceled
+		event.Type = TaskInterrupted
+		if context.Cause(ctx) == ErrTimeout {
+			event.Type = TaskTimedOut
+		}
+	case errors.Is(err, context.DeadlineExceeded):
+		result.Status = Failed
+		event.Type = TaskTimedOut
+	default:
+		result.Status = Failed
+		event.Type = TaskFailed
+	}
+	r.setResult(result)
+	r.emit(event)
+
+	return err
+}
+
+// attempt 执行一次任务并返回任务记录的输出，设置了任务超时时间时，任务使用一个会按时取消的 ctx
+func (r *Runner) attempt(ctx context.Context, id int, task Task) (json.RawMessage, error) {
+	if r.taskTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.taskTimeout)
+		defer cancel()
+	}
+
+	var output json.RawMessage
+	err := task(context.WithValue(ctx, outputKey{}, &output), id)
+	return output, err
+}
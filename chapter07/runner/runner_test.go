@@ -0,0 +1,463 @@
+// 这个示例程序测试 Runner 的超时、取消、失败策略和依赖关系
+package runner_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"notes.goinaction/chapter07/runner"
+)
+
+const checkMark = "\u2713"
+const ballotX = "\u2717"
+
+// TestTimeout 确认超时之后执行任务的 goroutine 会退出，并且不会再执行后面的任务
+func TestTimeout(t *testing.T) {
+	t.Log("Given the need to stop a runner that times out.")
+	{
+		r := runner.New(50 * time.Millisecond)
+
+		started := make(chan int, 3)
+		task := func(ctx context.Context, id int) error {
+			started <- id
+			<-ctx.Done()
+			return ctx.Err()
+		}
+		r.AddContext(task, task, task)
+
+		if _, err := r.Start(); err == runner.ErrTimeout {
+			t.Log("\tShould receive ErrTimeout.", checkMark)
+		} else {
+			t.Fatal("\tShould receive ErrTimeout.", ballotX, err)
+		}
+
+		select {
+		case <-r.Done():
+			t.Log("\tShould stop the goroutine running the tasks.", checkMark)
+		case <-time.After(time.Second):
+			t.Fatal("\tShould stop the goroutine running the tasks.", ballotX)
+		}
+
+		if n := len(started); n == 1 {
+			t.Log("\tShould not start the remaining tasks.", checkMark)
+		} else {
+			t.Error("\tShould not start the remaining tasks.", ballotX, n)
+		}
+	}
+}
+
+// TestTaskTimeout 确认任务超时只取消当前任务，后面的任务继续执行
+func TestTaskTimeout(t *testing.T) {
+	t.Log("Given the need to limit how long each task runs.")
+	{
+		r := runner.New(time.Second)
+		r.SetTaskTimeout(20 * time.Millisecond)
+
+		errs := make(chan error, 2)
+		task := func(ctx context.Context, id int) error {
+			<-ctx.Done()
+			errs <- ctx.Err()
+			return nil
+		}
+		r.AddContext(task, task)
+
+		if _, err := r.Start(); err == nil {
+			t.Log("\tShould finish all tasks.", checkMark)
+		} else {
+			t.Fatal("\tShould finish all tasks.", ballotX, err)
+		}
+
+		for i := 0; i < 2; i++ {
+			if err := <-errs; err == context.DeadlineExceeded {
+				t.Logf("\tShould cancel task #%d when it times out. %v", i, checkMark)
+			} else {
+				t.Errorf("\tShould cancel task #%d when it times out. %v %v", i, ballotX, err)
+			}
+		}
+	}
+}
+
+// TestPolicy 确认不同的失败策略会返回正确的错误和执行报告
+func TestPolicy(t *testing.T) {
+	fail := errors.New("fail")
+
+	t.Log("Given the need to handle failing tasks.")
+	{
+		t.Log("\tWhen using FailFast")
+		{
+			r := runner.New(time.Second)
+			r.AddContext(succeed, failWith(fail), succeed)
+
+			report, err := r.Start()
+			var taskErr *runner.TaskError
+			if errors.As(err, &taskErr) && taskErr.ID == 1 && errors.Is(err, fail) {
+				t.Log("\t\tShould return the error of task #1.", checkMark)
+			} else {
+				t.Fatal("\t\tShould return the error of task #1.", ballotX, err)
+			}
+
+			want := []runner.Status{runner.Succeeded, runner.Failed, runner.Skipped}
+			checkStatus(t, report, want)
+		}
+
+		t.Log("\tWhen using ContinueOnError")
+		{
+			r := runner.New(time.Second)
+			r.SetPolicy(runner.ContinueOnError)
+			r.AddContext(failWith(fail), succeed, failWith(fail))
+
+			report, err := r.Start()
+			var errs runner.Errors
+			if errors.As(err, &errs) && len(errs) == 2 && errs[0].ID == 0 && errs[1].ID == 2 {
+				t.Log("\t\tShould return the errors of task #0 and #2.", checkMark)
+			} else {
+				t.Fatal("\t\tShould return the errors of task #0 and #2.", ballotX, err)
+			}
+
+			want := []runner.Status{runner.Failed, runner.Succeeded, runner.Failed}
+			checkStatus(t, report, want)
+		}
+
+		t.Log("\tWhen retrying failed tasks")
+		{
+			r := runner.New(time.Second)
+			r.SetRetry(3, time.Millisecond)
+
+			calls := 0
+			r.AddContext(func(ctx context.Context, id int) error {
+				if calls++; calls < 3 {
+					return fail
+				}
+				return nil
+			})
+
+			report, err := r.Start()
+			if err == nil {
+				t.Log("\t\tShould succeed after retrying.", checkMark)
+			} else {
+				t.Fatal("\t\tShould succeed after retrying.", ballotX, err)
+			}
+
+			if attempts := report.Tasks[0].Attempts; attempts == 3 {
+				t.Log("\t\tShould record 3 attempts.", checkMark)
+			} else {
+				t.Error("\t\tShould record 3 attempts.", ballotX, attempts)
+			}
+		}
+	}
+}
+
+// succeed 是一个总是成功的任务
+func succeed(ctx context.Context, id int) error {
+	return nil
+}
+
+// failWith 返回一个总是返回 err 的任务
+func failWith(err error) runner.Task {
+	return func(ctx context.Context, id int) error {
+		return err
+	}
+}
+
+// checkStatus 检查报告里每个任务的状态
+func checkStatus(t *testing.T, report *runner.Report, want []runner.Status) {
+	t.Helper()
+
+	for id, status := range want {
+		if got := report.Tasks[id].Status; got == status {
+			t.Logf("\t\tShould mark task #%d as %v. %v", id, status, checkMark)
+		} else {
+			t.Errorf("\t\tShould mark task #%d as %v. %v %v", id, status, ballotX, got)
+		}
+	}
+}
+
+// TestGraph 确认有依赖关系的任务按照依赖的顺序并行执行
+func TestGraph(t *testing.T) {
+	t.Log("Given the need to run tasks with dependencies.")
+	{
+		t.Log("\tWhen adding tasks that form a cycle")
+		{
+			r := runner.New(time.Second)
+			r.AddTask("a", succeed, "c")
+			r.AddTask("b", succeed, "a")
+
+			if err := r.AddTask("c", succeed, "b"); errors.Is(err, runner.ErrCycle) {
+				t.Log("\t\tShould receive ErrCycle.", checkMark, err)
+			} else {
+				t.Error("\t\tShould receive ErrCycle.", ballotX, err)
+			}
+		}
+
+		t.Log("\tWhen a prerequisite fails")
+		{
+			r := runner.New(time.Second)
+			r.SetPolicy(runner.ContinueOnError)
+			r.SetParallelism(2)
+
+			// a 和 b 同时执行，只有两个任务都已经开始时才能返回
+			var wg sync.WaitGroup
+			wg.Add(2)
+			parallel := func(ctx context.Context, id int) error {
+				wg.Done()
+				wg.Wait()
+				return nil
+			}
+
+			r.AddTask("a", parallel)
+			r.AddTask("b", parallel)
+			r.AddTask("c", failWith(errors.New("fail")), "a")
+			r.AddTask("d", succeed, "c", "b")
+			r.AddTask("e", succeed, "d")
+			r.AddTask("f", succeed, "b")
+
+			report, err := r.Start()
+			var errs runner.Errors
+			if errors.As(err, &errs) && len(errs) == 1 && errs[0].Name == "c" {
+				t.Log("\t\tShould return the error of task c.", checkMark)
+			} else {
+				t.Fatal("\t\tShould return the error of task c.", ballotX, err)
+			}
+
+			want := []runner.Status{runner.Succeeded, runner.Succeeded, runner.Failed, runner.Skipped, runner.Skipped, runner.Succeeded}
+			checkStatus(t, report, want)
+		}
+
+		t.Log("\tWhen a dependency does not exist")
+		{
+			r := runner.New(time.Second)
+			r.AddTask("a", succeed, "missing")
+
+			if _, err := r.Start(); errors.Is(err, runner.ErrUnknownDependency) {
+				t.Log("\t\tShould receive ErrUnknownDependency.", checkMark)
+			} else {
+				t.Error("\t\tShould receive ErrUnknownDependency.", ballotX, err)
+			}
+		}
+	}
+}
+
+// TestShutdown 确认收到信号后 Runner 先等待正在执行的任务结束，再次收到信号时强制取消
+func TestShutdown(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Cannot send an interrupt signal on windows.")
+	}
+
+	t.Log("Given the need to shut down a runner on a signal.")
+	{
+		t.Log("\tWhen receiving one signal")
+		{
+			r := runner.New(time.Second)
+			r.SetGracePeriod(time.Second)
+
+			cleaned := false
+			r.AddCleanup(func() { cleaned = true })
+
+			r.AddContext(func(ctx context.Context, id int) error {
+				interrupt(t)
+				select {
+				case <-time.After(50 * time.Millisecond):
+					return nil
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}, succeed)
+
+			report, err := r.Start()
+			if err == runner.ErrInterrupt {
+				t.Log("\t\tShould receive ErrInterrupt.", checkMark)
+			} else {
+				t.Fatal("\t\tShould receive ErrInterrupt.", ballotX, err)
+			}
+
+			checkStatus(t, report, []runner.Status{runner.Succeeded, runner.Canceled})
+
+			if cleaned {
+				t.Log("\t\tShould run the cleanup hooks.", checkMark)
+			} else {
+				t.Error("\t\tShould run the cleanup hooks.", ballotX)
+			}
+		}
+
+		t.Log("\tWhen receiving a second signal")
+		{
+			r := runner.New(time.Minute)
+			r.SetGracePeriod(time.Minute)
+
+			r.AddContext(func(ctx context.Context, id int) error {
+				interrupt(t)
+				time.Sleep(10 * time.Millisecond)
+				interrupt(t)
+				<-ctx.Done()
+				return ctx.Err()
+			})
+
+			start := time.Now()
+			if _, err := r.Start(); err == runner.ErrInterrupt && time.Since(start) < time.Second {
+				t.Log("\t\tShould abort without waiting for the grace period.", checkMark)
+			} else {
+				t.Fatal("\t\tShould abort without waiting for the grace period.", ballotX, err)
+			}
+
+			select {
+			case <-r.Done():
+				t.Log("\t\tShould cancel the running task.", checkMark)
+			case <-time.After(time.Second):
+				t.Error("\t\tShould cancel the running task.", ballotX)
+			}
+		}
+	}
+}
+
+// TestNoSignals 确认不监听任何信号时，收到的信号不会中断 Runner
+func TestNoSignals(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Cannot send an interrupt signal on windows.")
+	}
+
+	t.Log("Given the need to run without listening to signals.")
+	{
+		// 测试自己接收中断信号，这样信号不会结束测试进程
+		received := make(chan os.Signal, 1)
+		signal.Notify(received, os.Interrupt)
+		defer signal.Stop(received)
+
+		r := runner.New(time.Second)
+		r.SetSignals()
+		r.AddContext(func(ctx context.Context, id int) error {
+			interrupt(t)
+			<-received
+			return nil
+		})
+
+		if _, err := r.Start(); err == nil {
+			t.Log("\tShould not be interrupted by a signal.", checkMark)
+		} else {
+			t.Error("\tShould not be interrupted by a signal.", ballotX, err)
+		}
+	}
+}
+
+// interrupt 给当前进程发送中断信号
+func interrupt(t *testing.T) {
+	p, err := os.FindProcess(os.Getpid())
+	if err == nil {
+		err = p.Signal(os.Interrupt)
+	}
+	if err != nil {
+		t.Error("\t\tShould be able to send a signal.", ballotX, err)
+	}
+}
+
+// TestResume 确认从状态文件恢复时跳过已经完成的任务，并且恢复它们的输出
+func TestResume(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+
+	var calls []string
+	newRunner := func(fail bool) *runner.Runner {
+		r := runner.New(time.Second)
+		r.SetCheckpoint(path)
+		r.SetResume(true)
+
+		r.AddTask("a", func(ctx context.Context, id int) error {
+			calls = append(calls, "a")
+			return runner.SetOutput(ctx, 42)
+		})
+		r.AddTask("b", func(ctx context.Context, id int) error {
+			calls = append(calls, "b")
+			if fail {
+				return errors.New("fail")
+			}
+			return nil
+		}, "a")
+		return r
+	}
+
+	t.Log("Given the need to resume a job that failed.")
+	{
+		if _, err := newRunner(true).Start(); err != nil {
+			t.Log("\tShould fail on the first run.", checkMark)
+		} else {
+			t.Fatal("\tShould fail on the first run.", ballotX)
+		}
+
+		calls = nil
+		report, err := newRunner(false).Start()
+		if err != nil {
+			t.Fatal("\tShould succeed on the second run.", ballotX, err)
+		}
+		t.Log("\tShould succeed on the second run.", checkMark)
+
+		if len(calls) == 1 && calls[0] == "b" {
+			t.Log("\tShould only run the task that failed.", checkMark)
+		} else {
+			t.Error("\tShould only run the task that failed.", ballotX, calls)
+		}
+
+		checkStatus(t, report, []runner.Status{runner.Resumed, runner.Succeeded})
+
+		if output := string(report.Tasks[0].Output); output == "42" {
+			t.Log("\tShould restore the output of the completed task.", checkMark)
+		} else {
+			t.Error("\tShould restore the output of the completed task.", ballotX, output)
+		}
+
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			t.Log("\tShould remove the checkpoint after all tasks succeed.", checkMark)
+		} else {
+			t.Error("\tShould remove the checkpoint after all tasks succeed.", ballotX, err)
+		}
+	}
+}
+
+// TestObserver 确认 Observer 按照顺序收到任务的事件和进度
+func TestObserver(t *testing.T) {
+	t.Log("Given the need to observe the progress of a runner.")
+	{
+		r := runner.New(time.Second)
+		r.SetPolicy(runner.ContinueOnError)
+		r.SetTaskTimeout(10 * time.Millisecond)
+
+		var events []runner.Event
+		r.AddObserver(runner.ObserverFunc(func(e runner.Event) {
+			events = append(events, e)
+		}))
+
+		r.AddContext(succeed, failWith(errors.New("fail")), func(ctx context.Context, id int) error {
+			<-ctx.Done()
+			return ctx.Err()
+		})
+		r.Start()
+
+		want := []runner.EventType{
+			runner.TaskStarted, runner.TaskFinished,
+			runner.TaskStarted, runner.TaskFailed,
+			runner.TaskStarted, runner.TaskTimedOut,
+		}
+		if len(events) != len(want) {
+			t.Fatal("\tShould receive 6 events.", ballotX, len(events))
+		}
+		t.Log("\tShould receive 6 events.", checkMark)
+
+		for i, e := range events {
+			if e.Type == want[i] && e.ID == i/2 {
+				t.Logf("\tShould receive %v for task #%d. %v", want[i], i/2, checkMark)
+			} else {
+				t.Errorf("\tShould receive %v for task #%d. %v %v #%d", want[i], i/2, ballotX, e.Type, e.ID)
+			}
+		}
+
+		if p := events[len(events)-1].Progress; p.Done == 3 && p.Percent() == 100 {
+			t.Log("\tShould report 100% progress after the last task.", checkMark)
+		} else {
+			t.Error("\tShould report 100% progress after the last task.", ballotX, p)
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"os"
+	"os/signal"
+	"time"
+
+	"notes.goinaction/cron"
+)
+
+// Clock 提供当前时间和定时器，测试时可以替换成手动推进的时钟
+type Clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration) Timer
+}
+
+// Timer 是 Clock 创建的定时器
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+}
+
+// RealClock 是使用系统时间的 Clock
+var RealClock Clock = realClock{}
+
+// realClock 使用 time 包实现 Clock
+type realClock struct{}
+
+// Now 实现 Clock 接口
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+// NewTimer 实现 Clock 接口
+func (realClock) NewTimer(d time.Duration) Timer {
+	return realTimer{time.NewTimer(d)}
+}
+
+// realTimer 使用 time.Timer 实现 Timer
+type realTimer struct {
+	t *time.Timer
+}
+
+// C 实现 Timer 接口
+func (t realTimer) C() <-chan time.Time {
+	return t.t.C
+}
+
+// Stop 实现 Timer 接口
+func (t realTimer) Stop() bool {
+	return t.t.Stop()
+}
+
+// Overlap 决定上一次执行还没有结束时，到了下一次执行的时间怎么处理
+type Overlap int
+
+const (
+	// Skip 跳过这一次执行
+	Skip Overlap = iota
+
+	// Queue 等上一次执行结束后马上执行，排队的次数没有限制
+	Queue
+
+	// CancelPrevious 取消上一次执行，等它结束后马上开始这一次执行
+	CancelPrevious
+)
+
+// Scheduler 按照计划反复执行 Runner 里的任务，同一时间只有一次执行
+type Scheduler struct {
+	runner   *Runner
+	schedule cron.Schedule
+	overlap  Overlap
+	jitter   time.Duration
+	clock    Clock
+	onRun    func(report *Report, err error)
+}
+
+// runDone 是一次执行结束的消息
+type runDone struct {
+	report *Report
+	err    error
+}
+
+// NewScheduler 返回一个按照计划 s 执行 r 的 Scheduler，默认跳过重叠的执行
+func NewScheduler(r *Runner, s cron.Schedule) *Scheduler {
+	return &Scheduler{
+		runner:   r,
+		schedule: s,
+		clock:    RealClock,
+	}
+}
+
+// SetOverlap 设置重叠执行的处理策略
+func (s *Scheduler) SetOverlap(o Overlap) {
+	s.overlap = o
+}
+
+// SetJitter 让每次执行在计划时间之后随机推迟 [0, d) 的时间，避免多个进程同时执行
+func (s *Scheduler) SetJitter(d time.Duration) {
+	s.jitter = d
+}
+
+// SetClock 替换 Scheduler 使用的时钟
+func (s *Scheduler) SetClock(c Clock) {
+	s.clock = c
+}
+
+// OnRun 设置每次执行结束后调用的函数
+func (s *Scheduler) OnRun(fn func(report *Report, err error)) {
+	s.onRun = fn
+}
+
+/*
+Run 按照计划执行任务，直到 ctx 被取消、计划不再执行，或者收到中断信号
+
+ctx 被取消时会取消正在执行的任务，等它结束后返回 ctx.Err()。其他错误只会传给 OnRun 设置的函数，
+不会停止 Scheduler。
+
+Run 在整个执行期间接收 Runner 设置的信号，而不是每次执行时由 Runner 注册和停止接收，
+这样两次执行之间收到的信号也不会丢失。两次执行之间收到信号时马上返回 ErrInterrupt；执行期间的信号
+交给 Runner 处理：第一个信号让它不再启动新的任务，并在宽限时间内等待正在执行的任务结束，
+第二个信号取消这些任务。这次执行结束后 Run 返回 ErrInterrupt，不再开始新的执行。
+*/
+func (s *Scheduler) Run(ctx context.Context) error {
+	if len(s.runner.signals) > 0 {
+		signal.Notify(s.runner.interrupt, s.runner.signals...)
+		defer signal.Stop(s.runner.interrupt)
+	}
+	s.runner.scheduled = true
+	defer func() { s.runner.scheduled = false }()
+
+	var (
+		running bool
+		queued  int
+		cancel  context.CancelFunc
+		done    = make(chan runDone)
+	)
+
+	// start 在新的 goroutine 里执行一次任务，等执行任务的 goroutine 退出后才报告结束，
+	// 这样下一次执行不会和上一次留下的任务同时运行
+	start := func() {
+		var runCtx context.Context
+		runCtx, cancel = context.WithCancel(ctx)
+		running = true
+
+		go func(ctx context.Context) {
+			report, err := s.runner.StartContext(ctx)
+			<-s.runner.Done()
+
+			// 任务都已经结束，重新生成报告，这样提前返回时正在执行的任务也有最终的状态
+			done <- runDone{s.runner.report(report.Duration), err}
+		}(runCtx)
+	}
+
+	// finish 处理一次执行的结束
+	finish := func(d runDone) {
+		running = false
+		cancel()
+		if s.onRun != nil {
+			s.onRun(d.report, d.err)
+		}
+	}
+
+	base := s.schedule.Next(s.clock.Now())
+	timer := s.timer(base)
+
+	for {
+		if base.IsZero() && !running && queued == 0 {
+			return nil
+		}
+
+		var fire <-chan time.Time
+		if timer != nil {
+			fire = timer.C()
+		}
+
+		// 执行期间由 Runner 接收信号，两个 goroutine 不能同时从同一个通道接收
+		var interrupt <-chan os.Signal
+		if !running {
+			interrupt = s.runner.interrupt
+		}
+
+		select {
+		case <-ctx.Done():
+			if timer != nil {
+				timer.Stop()
+			}
+			if running {
+				finish(<-done)
+			}
+			return ctx.Err()
+
+		case <-interrupt:
+			if timer != nil {
+				timer.Stop()
+			}
+			return ErrInterrupt
+
+		case d := <-done:
+			finish(d)
+			if errors.Is(d.err, ErrInterrupt) {
+				if timer != nil {
+					timer.Stop()
+				}
+				return ErrInterrupt
+			}
+
+			if queued > 0 {
+				queued--
+				start()
+			}
+
+		case <-fire:
+			switch {
+			case !running:
+				start()
+			case s.overlap == Queue:
+				queued++
+			case s.overlap == CancelPrevious:
+				cancel()
+				queued = 1
+			}
+
+			// 从计划时间开始计算下一次执行的时间，错过的执行不再补上
+			base = s.schedule.Next(base)
+			if now := s.clock.Now(); !base.IsZero() && base.Before(now) {
+				base = s.schedule.Next(now)
+			}
+			timer = s.timer(base)
+		}
+	}
+}
+
+// timer 返回一个在 t 加上随机推迟时间时触发的定时器，t 为零值时返回 nil
+func (s *Scheduler) timer(t time.Time) Timer {
+	if t.IsZero() {
+		return nil
+	}
+
+	if s.jitter > 0 {
+		t = t.Add(time.Duration(rand.Int63n(int64(s.jitter))))
+	}
+	return s.clock.NewTimer(t.Sub(s.clock.Now()))
+}
@@ -0,0 +1,341 @@
+// 这个示例程序使用手动推进的时钟测试 Scheduler 的重叠执行策略和信号处理
+package runner_test
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"notes.goinaction/chapter07/runner"
+	"notes.goinaction/cron"
+)
+
+// fakeClock 是手动推进的时钟，每创建一个定时器就通知 timers 通道
+type fakeClock struct {
+	m      sync.Mutex
+	now    time.Time
+	active []*fakeTimer
+	timers chan struct{}
+}
+
+// fakeTimer 在时钟推进到 at 时触发
+type fakeTimer struct {
+	clock   *fakeClock
+	at      time.Time
+	c       chan time.Time
+	stopped bool
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{
+		now:    time.Date(2021, 4, 17, 10, 0, 0, 0, time.UTC),
+		timers: make(chan struct{}, 16),
+	}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.m.Lock()
+	defer c.m.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) NewTimer(d time.Duration) runner.Timer {
+	c.m.Lock()
+	t := &fakeTimer{clock: c, at: c.now.Add(d), c: make(chan time.Time, 1)}
+	c.active = append(c.active, t)
+	c.m.Unlock()
+
+	c.timers <- struct{}{}
+	return t
+}
+
+// Advance 等待 Scheduler 创建下一个定时器，然后推进时钟并触发到期的定时器
+func (c *fakeClock) Advance(t *testing.T, d time.Duration) {
+	t.Helper()
+
+	select {
+	case <-c.timers:
+	case <-time.After(time.Second):
+		t.Fatal("\t\tShould create the next timer.", ballotX)
+	}
+
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	c.now = c.now.Add(d)
+	var active []*fakeTimer
+	for _, timer := range c.active {
+		if timer.stopped {
+			continue
+		}
+		if timer.at.After(c.now) {
+			active = append(active, timer)
+			continue
+		}
+		timer.c <- c.now
+	}
+	c.active = active
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.m.Lock()
+	defer t.clock.m.Unlock()
+
+	stopped := t.stopped
+	t.stopped = true
+	return !stopped
+}
+
+// TestOverlap 确认上一次执行还没有结束时，三种策略分别跳过、排队或者取消上一次执行
+func TestOverlap(t *testing.T) {
+	policies := []struct {
+		name    string
+		overlap runner.Overlap
+	}{
+		{"Skip", runner.Skip},
+		{"Queue", runner.Queue},
+		{"CancelPrevious", runner.CancelPrevious},
+	}
+
+	t.Log("Given the need to run tasks on a schedule.")
+	{
+		for _, p := range policies {
+			t.Logf("\tWhen using %s", p.name)
+			{
+				clock := newFakeClock()
+				started := make(chan int, 4)
+				release := make(chan struct{})
+				runs := make(chan error, 4)
+
+				r := runner.New(time.Minute)
+				calls := 0
+				r.AddContext(func(ctx context.Context, id int) error {
+					calls++
+					started <- calls
+					select {
+					case <-release:
+						return nil
+					case <-ctx.Done():
+						return ctx.Err()
+					}
+				})
+
+				s := runner.NewScheduler(r, cron.Every(time.Minute))
+				s.SetOverlap(p.overlap)
+				s.SetClock(clock)
+				s.OnRun(func(report *runner.Report, err error) {
+					runs <- err
+				})
+
+				ctx, cancel := context.WithCancel(context.Background())
+				stopped := make(chan error)
+				go func() {
+					stopped <- s.Run(ctx)
+				}()
+
+				// 第一次执行开始后，时间到了第二次执行，而第一次还没有结束
+				clock.Advance(t, time.Minute)
+				wait(t, started)
+				clock.Advance(t, time.Minute)
+
+				switch p.overlap {
+				case runner.Skip:
+					close(release)
+					if err := waitRun(t, runs); err == nil {
+						t.Log("\t\tShould finish the first run.", checkMark)
+					}
+					clock.Advance(t, time.Minute)
+				case runner.Queue:
+					close(release)
+					if err := waitRun(t, runs); err == nil {
+						t.Log("\t\tShould finish the first run.", checkMark)
+					}
+				case runner.CancelPrevious:
+					if err := waitRun(t, runs); err == context.Canceled {
+						t.Log("\t\tShould cancel the first run.", checkMark)
+					} else {
+						t.Error("\t\tShould cancel the first run.", ballotX, err)
+					}
+					close(release)
+				}
+
+				// 只有 Skip 需要等到下一次计划时间，其余两种策略在第一次执行结束后马上开始第二次执行
+				if n := wait(t, started); n == 2 {
+					t.Log("\t\tShould start the second run.", checkMark)
+				} else {
+					t.Error("\t\tShould start the second run.", ballotX, n)
+				}
+				waitRun(t, runs)
+
+				cancel()
+				if err := <-stopped; err == context.Canceled {
+					t.Log("\t\tShould stop when the context is canceled.", checkMark)
+				} else {
+					t.Error("\t\tShould stop when the context is canceled.", ballotX, err)
+				}
+			}
+		}
+	}
+}
+
+// wait 等待任务开始执行，返回这是第几次执行
+func wait(t *testing.T, started <-chan int) int {
+	t.Helper()
+
+	select {
+	case n := <-started:
+		return n
+	case <-time.After(time.Second):
+		t.Fatal("\t\tShould start a run.", ballotX)
+	}
+	return 0
+}
+
+// waitRun 等待一次执行结束，返回这次执行的错误
+func waitRun(t *testing.T, runs <-chan error) error {
+	t.Helper()
+
+	select {
+	case err := <-runs:
+		return err
+	case <-time.After(time.Second):
+		t.Fatal("\t\tShould finish a run.", ballotX)
+	}
+	return nil
+}
+
+// TestSchedulerSignals 确认 Scheduler 在两次执行之间也会因为信号停止，执行期间收到信号时先等待宽限时间
+func TestSchedulerSignals(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Cannot send an interrupt signal on windows.")
+	}
+
+	t.Log("Given the need to stop a scheduler on a signal.")
+	{
+		t.Log("\tWhen receiving a signal between runs")
+		{
+			clock := newFakeClock()
+			runs := make(chan error, 4)
+
+			r := runner.New(time.Minute)
+			r.AddContext(func(ctx context.Context, id int) error { return nil })
+
+			s := runner.NewScheduler(r, cron.Every(time.Minute))
+			s.SetClock(clock)
+			s.OnRun(func(report *runner.Report, err error) {
+				runs <- err
+			})
+
+			stopped := make(chan error, 1)
+			go func() {
+				stopped <- s.Run(context.Background())
+			}()
+
+			// 第一次执行结束后，Runner 停止接收信号不能影响 Scheduler
+			clock.Advance(t, time.Minute)
+			waitRun(t, runs)
+			interrupt(t)
+
+			select {
+			case err := <-stopped:
+				if err == runner.ErrInterrupt {
+					t.Log("\t\tShould return ErrInterrupt.", checkMark)
+				} else {
+					t.Error("\t\tShould return ErrInterrupt.", ballotX, err)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("\t\tShould stop without waiting for the next run.", ballotX)
+			}
+		}
+
+		t.Log("\tWhen receiving one signal during a run")
+		{
+			clock := newFakeClock()
+			reports := make(chan *runner.Report, 4)
+
+			r := runner.New(time.Minute)
+			r.SetGracePeriod(time.Second)
+			r.AddContext(func(ctx context.Context, id int) error {
+				interrupt(t)
+				select {
+				case <-time.After(50 * time.Millisecond):
+					return nil
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			})
+
+			s := runner.NewScheduler(r, cron.Every(time.Minute))
+			s.SetClock(clock)
+			s.OnRun(func(report *runner.Report, err error) {
+				reports <- report
+			})
+
+			stopped := make(chan error, 1)
+			go func() {
+				stopped <- s.Run(context.Background())
+			}()
+			clock.Advance(t, time.Minute)
+
+			if err := <-stopped; err == runner.ErrInterrupt {
+				t.Log("\t\tShould return ErrInterrupt after the run.", checkMark)
+			} else {
+				t.Error("\t\tShould return ErrInterrupt after the run.", ballotX, err)
+			}
+
+			checkStatus(t, <-reports, []runner.Status{runner.Succeeded})
+			if len(reports) == 0 {
+				t.Log("\t\tShould not start another run.", checkMark)
+			} else {
+				t.Error("\t\tShould not start another run.", ballotX)
+			}
+		}
+
+		t.Log("\tWhen receiving a second signal during a run")
+		{
+			clock := newFakeClock()
+			reports := make(chan *runner.Report, 4)
+
+			r := runner.New(time.Minute)
+			r.SetGracePeriod(time.Minute)
+			r.AddContext(func(ctx context.Context, id int) error {
+				interrupt(t)
+				time.Sleep(10 * time.Millisecond)
+				interrupt(t)
+				<-ctx.Done()
+				return ctx.Err()
+			})
+
+			s := runner.NewScheduler(r, cron.Every(time.Minute))
+			s.SetClock(clock)
+			s.OnRun(func(report *runner.Report, err error) {
+				reports <- report
+			})
+
+			stopped := make(chan error, 1)
+			go func() {
+				stopped <- s.Run(context.Background())
+			}()
+			clock.Advance(t, time.Minute)
+
+			select {
+			case err := <-stopped:
+				if err == runner.ErrInterrupt {
+					t.Log("\t\tShould abort without waiting for the grace period.", checkMark)
+				} else {
+					t.Error("\t\tShould abort without waiting for the grace period.", ballotX, err)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("\t\tShould abort without waiting for the grace period.", ballotX)
+			}
+
+			checkStatus(t, <-reports, []runner.Status{runner.Canceled})
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package work
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+)
+
+// PanicError 是 Task 或者提交的函数发生 panic 时得到的错误
+type PanicError struct {
+	// Value 是传给 panic 的值，Stack 是发生 panic 时的调用栈
+	Value interface{}
+	Stack []byte
+}
+
+// newPanicError 在 recover 之后记录 panic 的值和调用栈
+func newPanicError(v interface{}) *PanicError {
+	return &PanicError{Value: v, Stack: debug.Stack()}
+}
+
+// Error 实现 error 接口
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("work: task panicked: %v\n%s", e.Value, e.Stack)
+}
+
+// Future 是一个已经提交、可能还没有完成的工作的结果
+type Future[T any] struct {
+	fn    func() (T, error)
+	done  chan struct{}
+	value T
+	err   error
+}
+
+// Task 实现 Worker 接口，fn 发生 panic 时把它转换成 *PanicError
+func (f *Future[T]) Task() {
+	defer close(f.done)
+	defer func() {
+		if v := recover(); v != nil {
+			f.err = newPanicError(v)
+		}
+	}()
+
+	f.value, f.err = f.fn()
+}
+
+// drop 实现 dropper 接口，工作在执行之前被丢弃时让 Wait 返回 ErrDropped
+func (f *Future[T]) drop() {
+	f.err = ErrDropped
+	close(f.done)
+}
+
+// Done 返回一个在工作完成后关闭的通道
+func (f *Future[T]) Done() <-chan struct{} {
+	return f.done
+}
+
+// Wait 等待工作完成并返回它的结果，ctx 被取消时不再等待并返回 ctx.Err()，工作仍然会继续执行
+func (f *Future[T]) Wait(ctx context.Context) (T, error) {
+	select {
+	case <-f.done:
+		return f.value, f.err
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	}
+}
+
+/*
+Submit 把 fn 提交到工作池，返回用来取得结果的 Future
+
+因为 Go 的方法不能有类型参数，所以 Submit 是一个函数，而不是 Pool 的方法。
+和 Run 一样，没有队列时 Submit 返回时 fn 已经开始执行。提交失败时 Future 的 Wait 马上返回提交时的错误。
+*/
+func Submit[T any](p *Pool, fn func() (T, error)) *Future[T] {
+	return SubmitContext(context.Background(), p, fn)
+}
+
+// SubmitContext 和 Submit 一样，但是使用 Pool.SubmitContext 提交，队列已满时最多等到 ctx 被取消
+func SubmitContext[T any](ctx context.Context, p *Pool, fn func() (T, error)) *Future[T] {
+	f := &Future[T]{
+		fn:   fn,
+		done: make(chan struct{}),
+	}
+
+	if err := p.SubmitContext(ctx, f); err != nil {
+		f.err = err
+		close(f.done)
+	}
+	return f
+}
+
+// Result 是批量提交的一个工作的结果
+type Result[T any] struct {
+	Value T
+	Err   error
+}
+
+/*
+Batch 把 fns 依次提交到工作池，等待全部完成后按照提交的顺序返回结果
+
+每个工作的错误（包括 panic）记录在对应的 Result 里，不会影响其他工作。ctx 被取消时不再提交和等待，
+返回 ctx.Err()，这时还没有完成的工作的 Result 里的错误也是 ctx.Err()。
+*/
+func Batch[T any](ctx context.Context, p *Pool, fns ...func() (T, error)) ([]Result[T], error) {
+	futures := make([]*Future[T], 0, len(fns))
+	for _, fn := range fns {
+		if ctx.Err() != nil {
+			break
+		}
+		futures = append(futures, SubmitContext(ctx, p, fn))
+	}
+
+	var err error
+	results := make([]Result[T], len(fns))
+	for i := range results {
+		if i >= len(futures) {
+			err = ctx.Err()
+			results[i].Err = err
+			continue
+		}
+
+		select {
+		case <-futures[i].Done():
+			results[i].Value, results[i].Err = futures[i].value, futures[i].err
+		case <-ctx.Done():
+			err = ctx.Err()
+			results[i].Err = err
+		}
+	}
+
+	return results, err
+}
@@ -2,9 +2,12 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
 	"sync"
 	"time"
+	"unicode/utf8"
 
 	"notes.goinaction/chapter07/work"
 )
@@ -48,7 +51,9 @@ func main() {
 
 			go func() {
 				// 将任务提交执行。当 Run 返回时我们就知道任务已经处理完成
-				p.Run(&np)
+				if err := p.Run(&np); err != nil {
+					log.Println(err)
+				}
 				wg.Done()
 			}()
 		}
@@ -56,6 +61,69 @@ func main() {
 
 	wg.Wait()
 
+	// 批量提交有返回值的工作，结果按照提交的顺序返回
+	countNames(p)
+
 	// 让工作池停止工作，等待所有现有的工作完成
 	p.Shutdown()
+
+	// 工作池停止后再提交工作会返回错误，而不是 panic
+	if err := p.Run(&namePrinter{name: "周九"}); err != nil {
+		log.Println(err)
+	}
+
+	// 使用带队列的工作池，队列满时丢弃最早的工作
+	submitBurst()
+}
+
+// submitBurst 一次提交很多工作，只有一个 goroutine 和两个排队的位置，多余的工作会被丢弃
+func submitBurst() {
+	p := work.NewBuffered(1, 2)
+	p.SetOverflow(work.DropOldest)
+
+	futures := make([]*work.Future[string], 0, len(names))
+	for _, name := range names {
+		futures = append(futures, work.Submit(p, func() (string, error) {
+			time.Sleep(100 * time.Millisecond)
+			return name, nil
+		}))
+	}
+
+	for i, f := range futures {
+		name, err := f.Wait(context.Background())
+		log.Printf("Burst[%d]: %q %v\n", i, name, err)
+	}
+
+	p.Shutdown()
+}
+
+// countNames 批量计算每个名字的字数，空名字会让工作发生 panic，但不会影响其他工作和工作池
+func countNames(p *work.Pool) {
+	fns := make([]func() (int, error), 0, len(names)+1)
+	for _, name := range append(names, "") {
+		fns = append(fns, func() (int, error) {
+			if name == "" {
+				panic("empty name")
+			}
+			return utf8.RuneCountInString(name), nil
+		})
+	}
+
+	results, err := work.Batch(context.Background(), p, fns...)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	for i, r := range results {
+		var pe *work.PanicError
+		switch {
+		case errors.As(r.Err, &pe):
+			log.Printf("Batch[%d]: panic: %v\n", i, pe.Value)
+		case r.Err != nil:
+			log.Printf("Batch[%d]: %v\n", i, r.Err)
+		default:
+			log.Printf("Batch[%d]: %d\n", i, r.Value)
+		}
+	}
 }
@@ -1,23 +1,78 @@
 // Package work 包管理一个 goroutine 池来完成工作
 package work
 
-import "sync"
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+)
 
 // Worker 必须满足接口类型，才能使用工作池
 type Worker interface {
 	Task()
 }
 
+// Overflow 决定队列已满、也没有空闲的 goroutine 时怎么处理新提交的工作
+type Overflow int
+
+const (
+	// Block 等待队列里有空位，这是默认的策略
+	Block Overflow = iota
+
+	// Reject 不提交这个工作，返回 ErrQueueFull
+	Reject
+
+	// DropOldest 丢弃队列里最早提交的工作，把新的工作放进队列。被丢弃的 Future 返回 ErrDropped
+	DropOldest
+
+	// CallerRuns 在提交工作的 goroutine 里直接执行这个工作，这样提交工作的速度会自然地慢下来
+	CallerRuns
+)
+
+var (
+	// ErrShutdown 表示在调用 Shutdown 之后提交了工作
+	ErrShutdown = errors.New("work: pool has been shut down")
+
+	// ErrQueueFull 表示队列已满，工作没有被提交
+	ErrQueueFull = errors.New("work: queue is full")
+
+	// ErrDropped 表示工作在执行之前因为 DropOldest 策略被丢弃
+	ErrDropped = errors.New("work: dropped from queue")
+)
+
 // Pool 提供一个 goroutine 池，这个池可以完成任何已提交的 Worker 任务
 type Pool struct {
 	work chan Worker
 	wg   sync.WaitGroup
+
+	// m 保护 closed 和 overflow。不会阻塞的提交持有读锁，这样 Shutdown 不会关闭正在被写入的 work 通道
+	m        sync.RWMutex
+	closed   bool
+	overflow Overflow
+
+	// quit 在 Shutdown 时关闭，让阻塞在提交上的调用者返回。senders 是这些调用者，
+	// 它们不持有读锁，Shutdown 等它们都返回之后才关闭 work 通道
+	quit    chan struct{}
+	senders sync.WaitGroup
+
+	// onPanic 处理 Worker 的 Task 方法里发生的 panic
+	onPanic func(err *PanicError)
 }
 
-// New 创建一个新工作池
+// New 创建一个新工作池，没有队列，提交的工作要等到有 goroutine 空闲时才会被接收
 func New(maxGoroutines int) *Pool {
+	return NewBuffered(maxGoroutines, 0)
+}
+
+// NewBuffered 创建一个新工作池，所有 goroutine 都在忙时最多可以有 queueSize 个工作排队等待执行
+func NewBuffered(maxGoroutines, queueSize int) *Pool {
 	p := Pool{
-		work: make(chan Worker),
+		work: make(chan Worker, queueSize),
+		quit: make(chan struct{}),
+		onPanic: func(err *PanicError) {
+			log.Println(err)
+		},
 	}
 
 	p.wg.Add(maxGoroutines)
@@ -26,7 +81,7 @@ func New(maxGoroutines int) *Pool {
 			// 1. for range 循环会一直阻塞，直到从 work 通道收到一个 Worker 接口值
 			// 2. 一旦 work 通道被关闭，for range 循环就会结束，并调用 WaitGroup 的 Done 方法。然后 goroutine 终止
 			for w := range p.work {
-				w.Task()
+				p.do(w)
 			}
 			p.wg.Done()
 		}()
@@ -35,18 +90,159 @@ func New(maxGoroutines int) *Pool {
 	return &p
 }
 
+// SetPanicHandler 设置处理 Task 方法里的 panic 的函数，默认使用 log 包记录 panic 和调用栈，必须在提交工作之前调用
+func (p *Pool) SetPanicHandler(fn func(err *PanicError)) {
+	p.onPanic = fn
+}
+
+// SetOverflow 设置队列已满时的处理策略
+func (p *Pool) SetOverflow(o Overflow) {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	p.overflow = o
+}
+
+// do 执行一个 Worker，Task 方法发生 panic 时恢复过来，这样执行它的 goroutine 可以继续工作
+func (p *Pool) do(w Worker) {
+	defer func() {
+		if v := recover(); v != nil {
+			p.onPanic(newPanicError(v))
+		}
+	}()
+
+	w.Task()
+}
+
 /*
 Run 提交工作到工作池
 
-由于 work 通道是一个无缓冲的通道，调用者必须等待工作池里的某个 goroutine 接收到这个值才会返回。
+没有队列时，work 通道是一个无缓冲的通道，调用者必须等待工作池里的某个 goroutine 接收到这个值才会返回。
 这正是我们想要的，这样可以保证调用的 Run 返回时，提交的工作已经开始执行。
+调用 Shutdown 之后返回 ErrShutdown。
 */
-func (p *Pool) Run(w Worker) {
-	p.work <- w
+func (p *Pool) Run(w Worker) error {
+	return p.SubmitContext(context.Background(), w)
+}
+
+// TrySubmit 提交工作，不会阻塞。没有空闲的 goroutine 而且队列已满时返回 ErrQueueFull，不使用设置的策略
+func (p *Pool) TrySubmit(w Worker) error {
+	p.m.RLock()
+	defer p.m.RUnlock()
+
+	if p.closed {
+		return ErrShutdown
+	}
+
+	select {
+	case p.work <- w:
+		return nil
+	default:
+		return ErrQueueFull
+	}
 }
 
-// Shutdown 等待所有 goroutine 停止工作
+/*
+SubmitContext 提交工作，没有空闲的 goroutine 而且队列已满时按照设置的策略处理
+
+使用 Block 策略时一直等到工作被接收，或者 ctx 被取消并返回 ctx.Err()，或者调用了 Shutdown 并返回 ErrShutdown。
+没有队列时 DropOldest 没有可以丢弃的工作，和 Reject 一样返回 ErrQueueFull。
+*/
+func (p *Pool) SubmitContext(ctx context.Context, w Worker) error {
+	p.m.RLock()
+
+	if p.closed {
+		p.m.RUnlock()
+		return ErrShutdown
+	}
+
+	// 有空闲的 goroutine 或者队列还有空位时直接提交
+	select {
+	case p.work <- w:
+		p.m.RUnlock()
+		return nil
+	default:
+	}
+
+	switch p.overflow {
+	case Reject:
+		p.m.RUnlock()
+		return ErrQueueFull
+
+	case CallerRuns:
+		// 先释放读锁，这样执行工作的时候不会挡住 Shutdown，工作里也可以再提交工作
+		p.m.RUnlock()
+		p.do(w)
+		return nil
+
+	case DropOldest:
+		defer p.m.RUnlock()
+		return p.replaceOldest(w)
+	}
+
+	// 等待时不能持有读锁，否则 Shutdown 拿不到写锁，之后所有的 RLock 也会排在它后面，
+	// 正在执行的工作再提交工作时就会死锁
+	p.senders.Add(1)
+	p.m.RUnlock()
+	defer p.senders.Done()
+
+	select {
+	case p.work <- w:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-p.quit:
+		return ErrShutdown
+	}
+}
+
+// replaceOldest 丢弃队列里最早的工作，直到新的工作可以放进队列，调用时必须持有读锁
+func (p *Pool) replaceOldest(w Worker) error {
+	if cap(p.work) == 0 {
+		return ErrQueueFull
+	}
+
+	for {
+		select {
+		case p.work <- w:
+			return nil
+		default:
+		}
+
+		// 队列可能同时被 goroutine 取空，这时什么也不丢弃，再试一次
+		select {
+		case old := <-p.work:
+			if d, ok := old.(dropper); ok {
+				d.drop()
+			}
+		default:
+		}
+	}
+}
+
+// dropper 是被丢弃时需要得到通知的工作，例如 Future
+type dropper interface {
+	drop()
+}
+
+/*
+Shutdown 停止接收新的工作，等待所有 goroutine 完成已经提交的工作后停止，可以多次调用
+
+正在等待提交的调用者会返回 ErrShutdown，正在执行的工作再提交工作时也会得到 ErrShutdown。
+*/
 func (p *Pool) Shutdown() {
+	p.m.Lock()
+	if p.closed {
+		p.m.Unlock()
+		p.wg.Wait()
+		return
+	}
+	p.closed = true
+	close(p.quit)
+	p.m.Unlock()
+
+	// 等待阻塞在提交上的调用者都返回之后才关闭 work 通道，这样不会有人向已经关闭的通道发送
+	p.senders.Wait()
 	close(p.work)
 	p.wg.Wait()
 }
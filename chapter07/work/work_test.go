@@ -0,0 +1,310 @@
+// 这个示例程序测试工作池的 Future、批量提交和 panic 恢复
+package work_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"notes.goinaction/chapter07/work"
+)
+
+const checkMark = "\u2713"
+const ballotX = "\u2717"
+
+// TestFuture 确认 Future 能返回工作的结果和错误
+func TestFuture(t *testing.T) {
+	t.Log("Given the need to get a value back from submitted work.")
+	{
+		p := work.New(1)
+		defer p.Shutdown()
+
+		f := work.Submit(p, func() (int, error) { return 42, nil })
+		if v, err := f.Wait(context.Background()); v == 42 && err == nil {
+			t.Log("\tShould receive the value.", checkMark)
+		} else {
+			t.Error("\tShould receive the value.", ballotX, v, err)
+		}
+
+		errFail := errors.New("fail")
+		f = work.Submit(p, func() (int, error) { return 0, errFail })
+		if _, err := f.Wait(context.Background()); err == errFail {
+			t.Log("\tShould receive the error.", checkMark)
+		} else {
+			t.Error("\tShould receive the error.", ballotX, err)
+		}
+
+		release := make(chan struct{})
+		f = work.Submit(p, func() (int, error) {
+			<-release
+			return 1, nil
+		})
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		if _, err := f.Wait(ctx); err == context.DeadlineExceeded {
+			t.Log("\tShould stop waiting when the context is done.", checkMark)
+		} else {
+			t.Error("\tShould stop waiting when the context is done.", ballotX, err)
+		}
+		close(release)
+	}
+}
+
+// TestBatch 确认批量提交按照提交的顺序返回结果，并且 panic 不会让工作池的 goroutine 退出
+func TestBatch(t *testing.T) {
+	t.Log("Given the need to run a batch of work where one task panics.")
+	{
+		p := work.New(2)
+		defer p.Shutdown()
+
+		fns := make([]func() (int, error), 10)
+		for i := range fns {
+			fns[i] = func() (int, error) {
+				if i == 3 {
+					panic("boom")
+				}
+				time.Sleep(time.Duration(10-i) * time.Millisecond)
+				return i * i, nil
+			}
+		}
+
+		results, err := work.Batch(context.Background(), p, fns...)
+		if err != nil {
+			t.Fatal("\tShould finish the batch.", ballotX, err)
+		}
+
+		var pe *work.PanicError
+		if errors.As(results[3].Err, &pe) && pe.Value == "boom" {
+			t.Log("\tShould turn the panic into a PanicError.", checkMark)
+		} else {
+			t.Error("\tShould turn the panic into a PanicError.", ballotX, results[3].Err)
+		}
+
+		for i, r := range results {
+			if i != 3 && (r.Err != nil || r.Value != i*i) {
+				t.Fatalf("\tShould return results in submission order. %v #%d %v %v", ballotX, i, r.Value, r.Err)
+			}
+		}
+		t.Log("\tShould return results in submission order.", checkMark)
+	}
+}
+
+// panicker 是一个会发生 panic 的 Worker
+type panicker struct{}
+
+// Task 实现 Worker 接口
+func (panicker) Task() {
+	panic("worker")
+}
+
+// TestWorkerPanic 确认 Worker 发生 panic 时调用处理函数，并且 goroutine 可以继续工作
+func TestWorkerPanic(t *testing.T) {
+	t.Log("Given the need to survive a Worker that panics.")
+	{
+		p := work.New(1)
+		defer p.Shutdown()
+
+		panics := make(chan *work.PanicError, 1)
+		p.SetPanicHandler(func(err *work.PanicError) {
+			panics <- err
+		})
+
+		p.Run(panicker{})
+		if err := <-panics; err.Value == "worker" {
+			t.Log("\tShould pass the panic to the handler.", checkMark)
+		} else {
+			t.Error("\tShould pass the panic to the handler.", ballotX, err.Value)
+		}
+
+		f := work.Submit(p, func() (string, error) { return "ok", nil })
+		if v, err := f.Wait(context.Background()); v == "ok" && err == nil {
+			t.Log("\tShould keep the goroutine working.", checkMark)
+		} else {
+			t.Error("\tShould keep the goroutine working.", ballotX, v, err)
+		}
+	}
+}
+
+// blocker 是一个等到 release 被关闭才结束的 Worker
+type blocker struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+// Task 实现 Worker 接口
+func (b *blocker) Task() {
+	close(b.started)
+	<-b.release
+}
+
+// busy 让只有一个 goroutine 的工作池忙起来，并把队列填满，返回用来让工作池继续工作的通道
+func busy(t *testing.T, p *work.Pool, queued int) chan struct{} {
+	b := &blocker{started: make(chan struct{}), release: make(chan struct{})}
+	if err := p.Run(b); err != nil {
+		t.Fatal("\tShould start the blocking worker.", ballotX, err)
+	}
+	<-b.started
+
+	for i := 0; i < queued; i++ {
+		if err := p.TrySubmit(&blocker{started: make(chan struct{}), release: b.release}); err != nil {
+			t.Fatal("\tShould fill the queue.", ballotX, err)
+		}
+	}
+	return b.release
+}
+
+// TestOverflow 确认队列已满时按照设置的策略处理新提交的工作
+func TestOverflow(t *testing.T) {
+	t.Log("Given the need to handle work submitted to a full queue.")
+	{
+		p := work.NewBuffered(1, 1)
+		release := busy(t, p, 1)
+
+		if err := p.TrySubmit(panicker{}); err == work.ErrQueueFull {
+			t.Log("\tShould not block in TrySubmit.", checkMark)
+		} else {
+			t.Error("\tShould not block in TrySubmit.", ballotX, err)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		if err := p.SubmitContext(ctx, panicker{}); err == context.DeadlineExceeded {
+			t.Log("\tShould block until the context is done.", checkMark)
+		} else {
+			t.Error("\tShould block until the context is done.", ballotX, err)
+		}
+
+		p.SetOverflow(work.Reject)
+		if err := p.Run(panicker{}); err == work.ErrQueueFull {
+			t.Log("\tShould reject work with the Reject policy.", checkMark)
+		} else {
+			t.Error("\tShould reject work with the Reject policy.", ballotX, err)
+		}
+
+		p.SetOverflow(work.CallerRuns)
+		ran := false
+		f := work.Submit(p, func() (bool, error) {
+			ran = true
+			return true, nil
+		})
+		if v, err := f.Wait(context.Background()); v && ran && err == nil {
+			t.Log("\tShould run work in the caller with the CallerRuns policy.", checkMark)
+		} else {
+			t.Error("\tShould run work in the caller with the CallerRuns policy.", ballotX, err)
+		}
+
+		close(release)
+		p.Shutdown()
+	}
+	{
+		p := work.NewBuffered(1, 1)
+		p.SetOverflow(work.DropOldest)
+		release := make(chan struct{})
+		b := &blocker{started: make(chan struct{}), release: release}
+		p.Run(b)
+		<-b.started
+
+		old := work.Submit(p, func() (int, error) { return 1, nil })
+		latest := work.Submit(p, func() (int, error) { return 2, nil })
+		close(release)
+
+		if _, err := old.Wait(context.Background()); err == work.ErrDropped {
+			t.Log("\tShould drop the oldest work with the DropOldest policy.", checkMark)
+		} else {
+			t.Error("\tShould drop the oldest work with the DropOldest policy.", ballotX, err)
+		}
+
+		if v, err := latest.Wait(context.Background()); v == 2 && err == nil {
+			t.Log("\tShould run the latest work.", checkMark)
+		} else {
+			t.Error("\tShould run the latest work.", ballotX, v, err)
+		}
+		p.Shutdown()
+	}
+}
+
+// TestShutdown 确认 Shutdown 之后提交工作返回 ErrShutdown，而不是 panic
+func TestShutdown(t *testing.T) {
+	t.Log("Given the need to submit work after the pool has been shut down.")
+	{
+		p := work.NewBuffered(2, 4)
+		p.Shutdown()
+
+		if err := p.Run(panicker{}); err == work.ErrShutdown {
+			t.Log("\tShould return ErrShutdown from Run.", checkMark)
+		} else {
+			t.Error("\tShould return ErrShutdown from Run.", ballotX, err)
+		}
+
+		if err := p.TrySubmit(panicker{}); err == work.ErrShutdown {
+			t.Log("\tShould return ErrShutdown from TrySubmit.", checkMark)
+		} else {
+			t.Error("\tShould return ErrShutdown from TrySubmit.", ballotX, err)
+		}
+
+		if _, err := work.Submit(p, func() (int, error) { return 0, nil }).Wait(context.Background()); err == work.ErrShutdown {
+			t.Log("\tShould return ErrShutdown from a Future.", checkMark)
+		} else {
+			t.Error("\tShould return ErrShutdown from a Future.", ballotX, err)
+		}
+
+		p.Shutdown()
+		t.Log("\tShould allow Shutdown to be called again.", checkMark)
+	}
+}
+
+// TestShutdownBlocked 确认 Shutdown 让阻塞的提交返回 ErrShutdown，并且正在执行的工作再提交工作时不会死锁
+func TestShutdownBlocked(t *testing.T) {
+	t.Log("Given the need to shut down a pool while work is still being submitted.")
+	{
+		p := work.New(1)
+
+		started := make(chan struct{})
+		release := make(chan struct{})
+		f := work.Submit(p, func() (bool, error) {
+			close(started)
+			<-release
+			return true, p.TrySubmit(panicker{})
+		})
+		<-started
+
+		// 唯一的 goroutine 正在忙，没有队列，这次提交会一直阻塞
+		blocked := make(chan error, 1)
+		go func() {
+			blocked <- p.Run(panicker{})
+		}()
+		time.Sleep(20 * time.Millisecond)
+
+		stopped := make(chan struct{})
+		go func() {
+			p.Shutdown()
+			close(stopped)
+		}()
+
+		select {
+		case err := <-blocked:
+			if err == work.ErrShutdown {
+				t.Log("\tShould return ErrShutdown to a blocked submitter.", checkMark)
+			} else {
+				t.Error("\tShould return ErrShutdown to a blocked submitter.", ballotX, err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("\tShould return ErrShutdown to a blocked submitter.", ballotX)
+		}
+
+		close(release)
+		select {
+		case <-stopped:
+			t.Log("\tShould finish Shutdown.", checkMark)
+		case <-time.After(time.Second):
+			t.Fatal("\tShould finish Shutdown.", ballotX)
+		}
+
+		if _, err := f.Wait(context.Background()); err == work.ErrShutdown {
+			t.Log("\tShould reject work submitted by a running task.", checkMark)
+		} else {
+			t.Error("\tShould reject work submitted by a running task.", ballotX, err)
+		}
+	}
+}
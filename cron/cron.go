@@ -0,0 +1,214 @@
+// Package cron 包解析 crontab 格式的执行计划，计算计划下一次执行的时间。它不属于任何一章，保存的搜索和计划任务的示例都使用它
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule 计算一个计划任务下一次执行的时间
+type Schedule interface {
+	// Next 返回严格晚于 t 的下一次执行时间，计划不会再执行时返回零值
+	Next(t time.Time) time.Time
+}
+
+// Every 返回按固定间隔 d 执行的计划
+func Every(d time.Duration) Schedule {
+	return every(d)
+}
+
+// every 是按固定间隔执行的计划
+type every time.Duration
+
+// Next 实现了 Schedule 接口
+func (e every) Next(t time.Time) time.Time {
+	return t.Add(time.Duration(e))
+}
+
+// crontab 是按照 crontab 格式描述的计划，每个字段使用一个位图表示允许的值
+type crontab struct {
+	minute uint64
+	hour   uint64
+	dom    uint64
+	month  uint64
+	dow    uint64
+
+	// domStar 和 dowStar 记录日期和星期字段是否以 * 开头，它们决定两个字段之间是"与"还是"或"的关系
+	domStar bool
+	dowStar bool
+}
+
+// cronField 描述了 crontab 里一个字段的取值范围
+type cronField struct {
+	name     string
+	min, max int
+}
+
+// cronFields 是 crontab 的五个字段：分、时、日、月、星期
+var cronFields = []cronField{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+// shorthands 是常用计划的简写
+var shorthands = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+/*
+Parse 解析计划的描述
+
+支持以下几种写法：
+
+	"30 7 * * 1-5"  标准的五个字段的 crontab 格式，支持 *、列表、范围和步长
+	"@daily"        常用计划的简写，如 @hourly、@daily、@weekly
+	"@every 15m"    按固定间隔执行，间隔使用 time.ParseDuration 的格式
+*/
+func Parse(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+
+	if strings.HasPrefix(spec, "@every ") {
+		d, err := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(spec, "@every ")))
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule %q: %v", spec, err)
+		}
+		if d <= 0 {
+			return nil, fmt.Errorf("invalid schedule %q: interval must be positive", spec)
+		}
+		return every(d), nil
+	}
+
+	if expanded, exists := shorthands[spec]; exists {
+		spec = expanded
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("invalid schedule %q: want %d fields, got %d", spec, len(cronFields), len(fields))
+	}
+
+	var bits [5]uint64
+	for i, field := range fields {
+		b, err := parseCronField(field, cronFields[i])
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule %q: %v", spec, err)
+		}
+		bits[i] = b
+	}
+
+	// 星期字段里 7 和 0 都表示星期日
+	if bits[4]&(1<<7) != 0 {
+		bits[4] = bits[4]&^(1<<7) | 1
+	}
+
+	return &crontab{
+		minute:  bits[0],
+		hour:    bits[1],
+		dom:     bits[2],
+		month:   bits[3],
+		dow:     bits[4],
+		domStar: strings.HasPrefix(fields[2], "*"),
+		dowStar: strings.HasPrefix(fields[4], "*"),
+	}, nil
+}
+
+// parseCronField 把一个字段解析成位图，例如 "1-5"、"*/15"、"0,30"
+func parseCronField(field string, f cronField) (uint64, error) {
+	var bits uint64
+
+	for _, part := range strings.Split(field, ",") {
+		rangePart, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("%s: invalid step %q", f.name, part)
+			}
+			rangePart, step = part[:i], n
+		}
+
+		lo, hi := f.min, f.max
+		if rangePart != "*" {
+			bounds := strings.SplitN(rangePart, "-", 2)
+
+			var err error
+			if lo, err = strconv.Atoi(bounds[0]); err != nil {
+				return 0, fmt.Errorf("%s: invalid value %q", f.name, part)
+			}
+			hi = lo
+			if len(bounds) == 2 {
+				if hi, err = strconv.Atoi(bounds[1]); err != nil {
+					return 0, fmt.Errorf("%s: invalid value %q", f.name, part)
+				}
+			} else if step > 1 {
+				// "5/15" 表示从 5 开始，每 15 个单位执行一次
+				hi = f.max
+			}
+		}
+
+		if lo < f.min || hi > f.max || lo > hi {
+			return 0, fmt.Errorf("%s: value out of range %q", f.name, part)
+		}
+
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+
+	return bits, nil
+}
+
+// Next 实现了 Schedule 接口，从下一分钟开始逐个时间单位向后查找满足所有字段的时间
+func (c *crontab) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+
+	// 所有字段的组合至少每 4 年会重复一次，查找超过 5 年说明这个计划永远不会执行
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		if c.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+
+		if !c.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+
+		if c.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+
+		if c.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+
+		return t
+	}
+
+	return time.Time{}
+}
+
+// dayMatches 按照 crontab 的规则检查日期：日期和星期都有限制时，满足其中一个即可
+func (c *crontab) dayMatches(t time.Time) bool {
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+
+	if c.domStar || c.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
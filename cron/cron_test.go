@@ -0,0 +1,59 @@
+// 这个示例程序使用表组测试执行计划的解析和计算
+package cron_test
+
+import (
+	"testing"
+	"time"
+
+	"notes.goinaction/cron"
+)
+
+const checkMark = "\u2713"
+const ballotX = "\u2717"
+
+// TestSchedule 确认执行计划能够算出正确的下一次执行时间
+func TestSchedule(t *testing.T) {
+	// 2021-04-17 是星期六
+	from := time.Date(2021, 4, 17, 10, 30, 0, 0, time.UTC)
+
+	var schedules = []struct {
+		spec string
+		next time.Time
+	}{
+		{"*/15 * * * *", time.Date(2021, 4, 17, 10, 45, 0, 0, time.UTC)},
+		{"0 8 * * 1-5", time.Date(2021, 4, 19, 8, 0, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2021, 5, 1, 0, 0, 0, 0, time.UTC)},
+		{"30 10 * * 7", time.Date(2021, 4, 18, 10, 30, 0, 0, time.UTC)},
+		{"0 12 1 * 0", time.Date(2021, 4, 18, 12, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2021, 4, 17, 11, 0, 0, 0, time.UTC)},
+		{"@every 90m", time.Date(2021, 4, 17, 12, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+	}
+
+	t.Log("Given the need to compute the next run of a schedule.")
+	{
+		for _, s := range schedules {
+			t.Logf("\tWhen parsing %q", s.spec)
+			{
+				schedule, err := cron.Parse(s.spec)
+				if err != nil {
+					t.Fatal("\t\tShould be able to parse the schedule.", ballotX, err)
+				}
+
+				if next := schedule.Next(from); next.Equal(s.next) {
+					t.Logf("\t\tShould run next at %v %v", s.next, checkMark)
+				} else {
+					t.Errorf("\t\tShould run next at %v %v %v", s.next, ballotX, next)
+				}
+			}
+		}
+
+		for _, spec := range []string{"", "* * * *", "60 * * * *", "* * * * 8", "5-1 * * * *", "@every -1m"} {
+			if _, err := cron.Parse(spec); err != nil {
+				t.Logf("\tShould reject %q %v", spec, checkMark)
+			} else {
+				t.Errorf("\tShould reject %q %v", spec, ballotX)
+			}
+		}
+	}
+}